@@ -0,0 +1,84 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AuditAction identifies what an AuditRecord describes
+type AuditAction string
+
+const (
+	// AuditPutSecretValue records the pending version being stored
+	AuditPutSecretValue AuditAction = "PutSecretValue"
+
+	// AuditUpdateSecretVersionStage records a staging label being moved between versions
+	AuditUpdateSecretVersionStage AuditAction = "UpdateSecretVersionStage"
+
+	// AuditServiceHook records the outcome of a call into the Service
+	AuditServiceHook AuditAction = "ServiceHook"
+
+	// AuditServiceRollback records the outcome of asking a RollbackService to undo its changes
+	AuditServiceRollback AuditAction = "ServiceRollback"
+
+	// AuditServiceCleanup records the outcome of asking a CleaningService to clean up an orphaned version
+	AuditServiceCleanup AuditAction = "ServiceCleanup"
+)
+
+// AuditRecord describes a single action taken during a rotation, it never includes secret values
+type AuditRecord struct {
+	Time   time.Time   `json:"time"`
+	Action AuditAction `json:"action"`
+
+	// Actor identifies the rotator, see Config.AuditActor
+	Actor string `json:"actor,omitempty"`
+
+	SecretId           string `json:"secretId"`
+	Step               Step   `json:"step"`
+	ClientRequestToken string `json:"clientRequestToken"`
+
+	// VersionStages are the stages attached by PutSecretValue
+	VersionStages []string `json:"versionStages,omitempty"`
+
+	// VersionStage, MoveToVersionId and RemoveFromVersionId describe an UpdateSecretVersionStage call
+	VersionStage        string `json:"versionStage,omitempty"`
+	MoveToVersionId     string `json:"moveToVersionId,omitempty"`
+	RemoveFromVersionId string `json:"removeFromVersionId,omitempty"`
+
+	// Outcome is OutcomeSuccess or OutcomeFailure, with Error describing a failure
+	Outcome Outcome `json:"outcome"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// AuditSink stores audit records, implementations must be safe for concurrent use
+// Returning an error fails the step, so that an action is never left unaudited.
+type AuditSink interface {
+	Record(ctx context.Context, record AuditRecord) error
+}
+
+// audit completes record with the outcome of err and sends it to the AuditSink, returning err joined with any failure
+// to record it
+func (r *rotator) audit(ctx context.Context, record AuditRecord, err error) error {
+	if r.auditSink == nil {
+		return err
+	}
+
+	record.Time = time.Now().UTC()
+	record.Actor = r.auditActor
+	record.Outcome = OutcomeSuccess
+	if err != nil {
+		record.Outcome = OutcomeFailure
+		record.Error = err.Error()
+	}
+
+	if auditErr := r.auditSink.Record(ctx, record); auditErr != nil {
+		auditErr = fmt.Errorf("recording %s audit record: %w", record.Action, auditErr)
+		if err != nil {
+			return errors.Join(err, auditErr)
+		}
+		return auditErr
+	}
+	return err
+}
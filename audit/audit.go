@@ -0,0 +1,87 @@
+// Package audit stores rotation audit records in a tamper-evident, hash-chained JSON lines file
+// Every line includes the hash of the line before it, so modifying, removing or reordering records breaks the chain,
+// which Verify detects.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GenesisHash is the previous hash of the first entry in a chain
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// Entry is a single line of an audit file
+type Entry struct {
+	// Sequence starts at 1 and increases by one with each entry
+	Sequence uint64 `json:"seq"`
+
+	// PrevHash is the Hash of the previous entry, or GenesisHash for the first entry
+	PrevHash string `json:"prevHash"`
+
+	// Record is the JSON encoded rotate.AuditRecord, kept as written so that its hash can be recomputed
+	Record json.RawMessage `json:"record"`
+
+	// Hash covers Sequence, PrevHash and Record
+	Hash string `json:"hash"`
+}
+
+// ComputeHash returns the hash an entry should have
+func (e *Entry) ComputeHash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n", e.Sequence, e.PrevHash)
+	h.Write(e.Record)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ChainError describes where an audit file fails verification
+type ChainError struct {
+	// Line is the 1 based line number of the first invalid entry
+	Line   int
+	Reason string
+}
+
+func (c *ChainError) Error() string {
+	return fmt.Sprintf("audit: line %d: %s", c.Line, c.Reason)
+}
+
+// Verify checks that every entry read from r is intact and chained to the entry before it
+// It returns the number of valid entries, and a *ChainError describing the first invalid entry.
+func Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	prev := Entry{Hash: GenesisHash}
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return count, &ChainError{Line: line, Reason: "malformed entry: " + err.Error()}
+		}
+		if entry.Sequence != prev.Sequence+1 {
+			return count, &ChainError{Line: line, Reason: fmt.Sprintf("sequence %d follows %d", entry.Sequence, prev.Sequence)}
+		}
+		if entry.PrevHash != prev.Hash {
+			return count, &ChainError{Line: line, Reason: "previous hash does not match the preceding entry"}
+		}
+		if entry.Hash != entry.ComputeHash() {
+			return count, &ChainError{Line: line, Reason: "hash does not match the entry contents"}
+		}
+
+		prev = entry
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("audit: %w", err)
+	}
+	return count, nil
+}
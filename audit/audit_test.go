@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"context"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRecord(action rotate.AuditAction, token string) rotate.AuditRecord {
+	return rotate.AuditRecord{
+		Time:               time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC),
+		Action:             action,
+		SecretId:           "my-secret",
+		Step:               rotate.StepCreate,
+		ClientRequestToken: token,
+		Outcome:            rotate.OutcomeSuccess,
+	}
+}
+
+func writeTestFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	f, err := Open(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, f.Record(context.TODO(), testRecord(rotate.AuditServiceHook, "version-2")))
+	assert.NoError(t, f.Record(context.TODO(), testRecord(rotate.AuditPutSecretValue, "version-2")))
+	assert.NoError(t, f.Close())
+
+	// reopening continues the existing chain
+	f, err = Open(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, f.Record(context.TODO(), testRecord(rotate.AuditUpdateSecretVersionStage, "version-2")))
+	assert.NoError(t, f.Close())
+	return path
+}
+
+func TestFile(t *testing.T) {
+	path := writeTestFile(t)
+
+	count, err := VerifyFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Contains(t, lines[0], `"seq":1,"prevHash":"`+GenesisHash+`"`)
+		assert.Contains(t, lines[1], `"action":"PutSecretValue"`)
+		assert.Contains(t, lines[2], `"seq":3`)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tamper := map[string]struct {
+		edit func(lines []string) []string
+		line int
+	}{
+		"modified record": {
+			edit: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"outcome":"success"`, `"outcome":"failure"`, 1)
+				return lines
+			},
+			line: 2,
+		},
+		"removed entry": {
+			edit: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			line: 2,
+		},
+		"reordered entries": {
+			edit: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			line: 2,
+		},
+		"malformed entry": {
+			edit: func(lines []string) []string {
+				lines[2] = "{"
+				return lines
+			},
+			line: 3,
+		},
+	}
+
+	for name, test := range tamper {
+		t.Run(name, func(t *testing.T) {
+			path := writeTestFile(t)
+			data, err := os.ReadFile(path)
+			assert.NoError(t, err)
+
+			lines := test.edit(strings.Split(strings.TrimSpace(string(data)), "\n"))
+			count, err := Verify(strings.NewReader(strings.Join(lines, "\n")))
+
+			var chainErr *ChainError
+			if assert.ErrorAs(t, err, &chainErr) {
+				assert.Equal(t, test.line, chainErr.Line)
+			}
+			assert.Equal(t, test.line-1, count)
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		count, err := Verify(strings.NewReader(""))
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"os"
+	"sync"
+)
+
+// File is a rotate.AuditSink that appends hash-chained entries to a JSON lines file
+// Lambda storage does not outlive the execution environment, so File suits long-running hosts or a mounted file
+// system. Only one File should write to a path at a time.
+type File struct {
+	mu   sync.Mutex
+	file *os.File
+	last Entry
+}
+
+// Open opens or creates the audit file at path, continuing the chain from its last entry
+func Open(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	last := Entry{Hash: GenesisHash}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err = json.Unmarshal(scanner.Bytes(), &last); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("audit: reading last entry: %w", err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	return &File{file: file, last: last}, nil
+}
+
+// Record appends record to the file, syncing it to storage before returning
+func (f *File) Record(_ context.Context, record rotate.AuditRecord) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry := Entry{Sequence: f.last.Sequence + 1, PrevHash: f.last.Hash, Record: raw}
+	entry.Hash = entry.ComputeHash()
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	if _, err = f.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	if err = f.file.Sync(); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	f.last = entry
+	return nil
+}
+
+// Close closes the underlying file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// VerifyFile verifies the audit file at path, see Verify
+func VerifyFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("audit: %w", err)
+	}
+	defer file.Close()
+	return Verify(file)
+}
+
+// Ensure that *File remains AuditSink compatible
+func _(f *File) rotate.AuditSink {
+	return f
+}
@@ -0,0 +1,120 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	t.Run("create records the hook and put", func(t *testing.T) {
+		event := testEvent(StepCreate)
+		currentValue := "current"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &currentValue},
+			},
+		}
+		sink := &recordingAuditSink{}
+		r := testRotator(t, sm, &mockService{OnCreate: StringSecret("new-secret")}).(*rotator)
+		r.auditSink, r.auditActor = sink, "rotation-lambda"
+
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		if !assert.Len(t, sink.Records, 2) {
+			return
+		}
+
+		hook, put := sink.Records[0], sink.Records[1]
+		assert.Equal(t, AuditServiceHook, hook.Action)
+		assert.Equal(t, StepCreate, hook.Step)
+		assert.Equal(t, OutcomeSuccess, hook.Outcome)
+
+		assert.Equal(t, AuditPutSecretValue, put.Action)
+		assert.Equal(t, "rotation-lambda", put.Actor)
+		assert.Equal(t, event.SecretId, put.SecretId)
+		assert.Equal(t, event.ClientRequestToken, put.ClientRequestToken)
+		assert.Equal(t, []string{AWSPENDING}, put.VersionStages)
+		assert.False(t, put.Time.IsZero())
+	})
+
+	t.Run("finish records the stage move", func(t *testing.T) {
+		event := testEvent(StepFinish)
+		currentVersion := testVersionId()
+		currentValue, pendingValue := "current", "pending"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: currentVersion, SecretString: &currentValue},
+				AWSPENDING: {VersionId: &event.ClientRequestToken, SecretString: &pendingValue},
+			},
+		}
+		sink := &recordingAuditSink{}
+		r := testRotator(t, sm, &mockService{}).(*rotator)
+		r.auditSink = sink
+
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		if assert.Len(t, sink.Records, 2) {
+			update := sink.Records[1]
+			assert.Equal(t, AuditUpdateSecretVersionStage, update.Action)
+			assert.Equal(t, AWSCURRENT, update.VersionStage)
+			assert.Equal(t, event.ClientRequestToken, update.MoveToVersionId)
+			assert.Equal(t, *currentVersion, update.RemoveFromVersionId)
+		}
+	})
+
+	t.Run("failed hook is recorded", func(t *testing.T) {
+		event := testEvent(StepTest)
+		pendingValue := "pending"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSPENDING: {VersionId: &event.ClientRequestToken, SecretString: &pendingValue},
+			},
+		}
+		sink := &recordingAuditSink{}
+		rejected := errors.New("rejected")
+		r := testRotator(t, sm, &failingService{err: rejected}).(*rotator)
+		r.auditSink = sink
+
+		assert.ErrorIs(t, r.Handle(context.TODO(), event), rejected)
+		if assert.Len(t, sink.Records, 1) {
+			assert.Equal(t, OutcomeFailure, sink.Records[0].Outcome)
+			assert.Equal(t, "rejected", sink.Records[0].Error)
+		}
+	})
+
+	t.Run("audit failures fail the step", func(t *testing.T) {
+		event := testEvent(StepCreate)
+		currentValue := "current"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &currentValue},
+			},
+		}
+		unavailable := errors.New("disk full")
+		r := testRotator(t, sm, &mockService{OnCreate: StringSecret("new-secret")}).(*rotator)
+		r.auditSink = &recordingAuditSink{err: unavailable}
+
+		err := r.Handle(context.TODO(), event)
+		assert.ErrorIs(t, err, unavailable)
+		// the pending secret is not stored when the hook could not be audited
+		assertApiCounts(t, sm, apiCounts{Lookups: 2})
+	})
+}
+
+type recordingAuditSink struct {
+	sync.Mutex
+	Records []AuditRecord
+	err     error
+}
+
+func (s *recordingAuditSink) Record(_ context.Context, record AuditRecord) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.Records = append(s.Records, record)
+	return nil
+}
@@ -0,0 +1,52 @@
+package rotate
+
+// SecretEncoder converts a Secret back into the representation it was parsed from
+type SecretEncoder interface {
+	Encode(Secret) (Secret, error)
+}
+
+// SecretCodec is a SecretParser that can also reverse its parsing
+type SecretCodec interface {
+	SecretParser
+	SecretEncoder
+}
+
+// EncodingService is a ParsingService that wants each created Secret to pass through a SecretEncoder before it is stored
+type EncodingService interface {
+	ParsingService
+	SecretEncoder
+}
+
+// Chain returns a SecretCodec that parses a Secret through each parser in order
+// Encoding applies the parsers in reverse, skipping any that are not also a SecretEncoder. The final parser of a chain
+// is commonly a struct parser such as jsonsecret.Parser, whose secrets already serialize themselves through Value().
+func Chain(parsers ...SecretParser) SecretCodec {
+	return chain(parsers)
+}
+
+type chain []SecretParser
+
+func (c chain) Parse(secret Secret) (Secret, error) {
+	for _, parser := range c {
+		var err error
+		if secret, err = parser.Parse(secret); err != nil {
+			return secret, err
+		}
+	}
+	return secret, nil
+}
+
+func (c chain) Encode(secret Secret) (Secret, error) {
+	for i := len(c) - 1; i >= 0; i-- {
+		encoder, ok := c[i].(SecretEncoder)
+		if !ok {
+			continue
+		}
+
+		var err error
+		if secret, err = encoder.Encode(secret); err != nil {
+			return secret, err
+		}
+	}
+	return secret, nil
+}
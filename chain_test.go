@@ -0,0 +1,92 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	t.Run("parses through each parser in order", func(t *testing.T) {
+		secret, err := Chain(prefixCodec("outer:"), prefixCodec("inner:")).Parse(StringSecret("outer:inner:value"))
+		assert.NoError(t, err)
+		assert.Equal(t, StringSecret("value"), secret)
+	})
+
+	t.Run("encodes through each encoder in reverse", func(t *testing.T) {
+		secret, err := Chain(prefixCodec("outer:"), prefixCodec("inner:")).Encode(StringSecret("value"))
+		assert.NoError(t, err)
+		assert.Equal(t, StringSecret("outer:inner:value"), secret)
+	})
+
+	t.Run("parsers without an encoder are skipped when encoding", func(t *testing.T) {
+		c := Chain(prefixCodec("outer:"), upperParser{})
+
+		parsed, err := c.Parse(StringSecret("outer:value"))
+		assert.NoError(t, err)
+		assert.Equal(t, StringSecret("VALUE"), parsed)
+
+		encoded, err := c.Encode(StringSecret("NEXT"))
+		assert.NoError(t, err)
+		assert.Equal(t, StringSecret("outer:NEXT"), encoded)
+	})
+
+	t.Run("stops at the first parser error", func(t *testing.T) {
+		_, err := Chain(prefixCodec("outer:"), prefixCodec("inner:")).Parse(StringSecret("inner:outer:value"))
+		assert.Error(t, err)
+	})
+
+	t.Run("rotator stores created secrets in their layered encoding", func(t *testing.T) {
+		event := testEvent(StepCreate)
+		current := "outer:inner:old"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &current},
+			},
+		}
+		svc := &chainService{SecretCodec: Chain(prefixCodec("outer:"), prefixCodec("inner:"))}
+
+		assert.NoError(t, testRotator(t, sm, svc).Handle(context.TODO(), event))
+		assert.Equal(t, StringSecret("old"), svc.current)
+		if assert.Len(t, sm.Creations, 1) {
+			assert.Equal(t, "outer:inner:old-rotated", *sm.Creations[0].SecretString)
+		}
+	})
+}
+
+// prefixCodec is encoded by prepending itself to a secret
+type prefixCodec string
+
+func (p prefixCodec) Parse(secret Secret) (Secret, error) {
+	value, _ := secret.Value()
+	if !strings.HasPrefix(string(value), string(p)) {
+		return secret, errors.New("missing prefix " + string(p))
+	}
+	return StringSecret(strings.TrimPrefix(string(value), string(p))), nil
+}
+
+func (p prefixCodec) Encode(secret Secret) (Secret, error) {
+	value, _ := secret.Value()
+	return StringSecret(string(p) + string(value)), nil
+}
+
+type upperParser struct{}
+
+func (upperParser) Parse(secret Secret) (Secret, error) {
+	value, _ := secret.Value()
+	return StringSecret(strings.ToUpper(string(value))), nil
+}
+
+type chainService struct {
+	SecretCodec
+	current Secret
+}
+
+func (c *chainService) Create(_ context.Context, current Secret) (Secret, error) {
+	c.current = current
+	value, _ := current.Value()
+	return StringSecret(string(value) + "-rotated"), nil
+}
@@ -0,0 +1,100 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"io"
+	"strings"
+)
+
+// Base64 returns a rotate.SecretCodec for secrets stored as standard base64 text
+// Parsed secrets are binary, and encoded secrets are written back as strings.
+func Base64() rotate.SecretCodec {
+	return &textCodec{
+		decode: func(s string) ([]byte, error) {
+			return base64.StdEncoding.DecodeString(s)
+		},
+		encode: base64.StdEncoding.EncodeToString,
+	}
+}
+
+// Hex returns a rotate.SecretCodec for secrets stored as hexadecimal text
+// Parsed secrets are binary, and encoded secrets are written back as strings.
+func Hex() rotate.SecretCodec {
+	return &textCodec{
+		decode: hex.DecodeString,
+		encode: hex.EncodeToString,
+	}
+}
+
+type textCodec struct {
+	decode func(string) ([]byte, error)
+	encode func([]byte) string
+}
+
+func (c *textCodec) Parse(secret rotate.Secret) (rotate.Secret, error) {
+	data, err := secret.Value()
+	if err != nil {
+		return secret, err
+	}
+
+	decoded, err := c.decode(strings.TrimSpace(string(data)))
+	if err != nil {
+		return secret, err
+	}
+	return rotate.BinarySecret(decoded), nil
+}
+
+func (c *textCodec) Encode(secret rotate.Secret) (rotate.Secret, error) {
+	data, err := secret.Value()
+	if err != nil {
+		return secret, err
+	}
+	return rotate.StringSecret(c.encode(data)), nil
+}
+
+// Gzip returns a rotate.SecretCodec for gzip compressed secrets, which are always binary
+func Gzip() rotate.SecretCodec {
+	return gzipCodec{}
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Parse(secret rotate.Secret) (rotate.Secret, error) {
+	data, err := secret.Value()
+	if err != nil {
+		return secret, err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return secret, err
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return secret, err
+	}
+	return rotate.BinarySecret(decoded), nil
+}
+
+func (gzipCodec) Encode(secret rotate.Secret) (rotate.Secret, error) {
+	data, err := secret.Value()
+	if err != nil {
+		return secret, err
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err = writer.Write(data); err != nil {
+		return secret, err
+	}
+	if err = writer.Close(); err != nil {
+		return secret, err
+	}
+	return rotate.BinarySecret(buf.Bytes()), nil
+}
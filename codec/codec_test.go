@@ -0,0 +1,105 @@
+package codec_test
+
+import (
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/codec"
+	"github.com/printerlogic/go-secretsmanager-rotate/jsonsecret"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	cases := map[string]struct {
+		codec   rotate.SecretCodec
+		encoded rotate.Secret
+		decoded string
+	}{
+		"base64": {codec.Base64(), rotate.StringSecret("aHVudGVyMg=="), "hunter2"},
+		"hex":    {codec.Hex(), rotate.StringSecret("68756e74657232"), "hunter2"},
+	}
+
+	for name, c := range cases {
+		t.Run(name+" parses into a binary secret", func(t *testing.T) {
+			secret, err := c.codec.Parse(c.encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, rotate.BinarySecret(c.decoded), secret)
+		})
+
+		t.Run(name+" encodes back to a string secret", func(t *testing.T) {
+			secret, err := c.codec.Encode(rotate.BinarySecret(c.decoded))
+			assert.NoError(t, err)
+			assert.Equal(t, c.encoded, secret)
+		})
+
+		t.Run(name+" rejects malformed input", func(t *testing.T) {
+			_, err := c.codec.Parse(rotate.StringSecret("!not encoded!"))
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("base64 ignores surrounding whitespace", func(t *testing.T) {
+		secret, err := codec.Base64().Parse(rotate.StringSecret("aHVudGVyMg==\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, rotate.BinarySecret("hunter2"), secret)
+	})
+
+	t.Run("gzip round trips", func(t *testing.T) {
+		encoded, err := codec.Gzip().Encode(rotate.StringSecret("hunter2"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, encoded.Binary())
+
+		decoded, err := codec.Gzip().Parse(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, rotate.BinarySecret("hunter2"), decoded)
+	})
+
+	t.Run("gzip rejects uncompressed input", func(t *testing.T) {
+		_, err := codec.Gzip().Parse(rotate.BinarySecret("hunter2"))
+		assert.Error(t, err)
+	})
+}
+
+func TestLayeredChain(t *testing.T) {
+	// base64 of gzip of JSON, as written by a legacy deployment tool
+	layers := rotate.Chain(codec.Base64(), codec.Gzip(), jsonsecret.Parser(&Credentials{}))
+
+	source, err := rotate.Chain(codec.Base64(), codec.Gzip()).Encode(rotate.StringSecret(`{"username": "app", "password": "old", "port": 5432}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	parsed, err := layers.Parse(source)
+	if !assert.NoError(t, err) {
+		return
+	}
+	creds := parsed.(*Credentials)
+	assert.Equal(t, "old", creds.Password)
+
+	creds.Password = "new"
+	encoded, err := layers.Encode(creds)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, encoded.Binary(), "outermost base64 layer should produce a string secret")
+
+	again, err := layers.Parse(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", again.(*Credentials).Password)
+	assert.Equal(t, 5432, again.(*Credentials).Port)
+}
+
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Port     int    `json:"port"`
+}
+
+func (c *Credentials) Binary() bool {
+	return false
+}
+
+func (c *Credentials) Value() ([]byte, error) {
+	return jsonsecret.Marshal(c)
+}
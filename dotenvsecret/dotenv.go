@@ -0,0 +1,201 @@
+package dotenvsecret
+
+import (
+	"fmt"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"regexp"
+	"strings"
+)
+
+// Parser returns a rotate.SecretParser that parses dotenv documents into an *Env
+func Parser() rotate.SecretParser {
+	return parser{}
+}
+
+type parser struct{}
+
+func (parser) Parse(s rotate.Secret) (rotate.Secret, error) {
+	data, err := s.Value()
+	if err != nil {
+		return s, err
+	}
+	return Parse(data)
+}
+
+// Env is an ordered set of KEY=VALUE entries parsed from a dotenv document
+// Comments, blank lines and the original formatting of entries are kept, so that serializing an Env only changes the
+// entries that were modified.
+type Env struct {
+	rotate.Redacted
+	lines []*line
+}
+
+// line is either a complete entry or text that is carried through untouched, such as a comment or blank line
+type line struct {
+	// raw is the original text, including the trailing newline when present
+	raw string
+
+	entry   bool
+	export  bool
+	key     string
+	value   string
+	comment string
+
+	// dirty entries are rendered from their fields rather than raw
+	dirty bool
+}
+
+func (e *Env) Binary() bool {
+	return false
+}
+
+func (e *Env) Value() ([]byte, error) {
+	var b strings.Builder
+	for _, l := range e.lines {
+		if !l.dirty {
+			b.WriteString(l.raw)
+			continue
+		}
+		b.WriteString(render(l))
+		if strings.HasSuffix(l.raw, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// Ensure that *Env remains Secret compatible
+func _(e *Env) rotate.Secret {
+	return e
+}
+
+// Clone returns a copy of the Env that can be modified without affecting the original
+// Services should clone the current secret in Create rather than modifying it in place.
+func (e *Env) Clone() *Env {
+	clone := &Env{lines: make([]*line, len(e.lines))}
+	for i, l := range e.lines {
+		copied := *l
+		clone.lines[i] = &copied
+	}
+	return clone
+}
+
+// Get returns the value of key, when a key is repeated the last entry wins
+func (e *Env) Get(key string) (string, bool) {
+	if l := e.find(key); l != nil {
+		return l.value, true
+	}
+	return "", false
+}
+
+// Set updates the value of key in place, or appends a new entry when key is not present
+func (e *Env) Set(key, value string) error {
+	if !validKey.MatchString(key) {
+		return fmt.Errorf("dotenvsecret: invalid key %q", key)
+	}
+
+	if l := e.find(key); l != nil {
+		l.value = value
+		l.dirty = true
+		return nil
+	}
+
+	if n := len(e.lines); n > 0 && !strings.HasSuffix(e.lines[n-1].raw, "\n") {
+		e.lines[n-1].raw += "\n"
+	}
+	e.lines = append(e.lines, &line{raw: "\n", entry: true, key: key, value: value, dirty: true})
+	return nil
+}
+
+// Delete removes every entry for key
+func (e *Env) Delete(key string) {
+	lines := e.lines[:0]
+	for _, l := range e.lines {
+		if !l.entry || l.key != key {
+			lines = append(lines, l)
+		}
+	}
+	e.lines = lines
+}
+
+// Keys returns each key in the order it first appears
+func (e *Env) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, l := range e.lines {
+		if l.entry && !seen[l.key] {
+			seen[l.key] = true
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Map returns the entries as a map
+func (e *Env) Map() map[string]string {
+	values := make(map[string]string)
+	for _, l := range e.lines {
+		if l.entry {
+			values[l.key] = l.value
+		}
+	}
+	return values
+}
+
+func (e *Env) find(key string) *line {
+	for i := len(e.lines) - 1; i >= 0; i-- {
+		if e.lines[i].entry && e.lines[i].key == key {
+			return e.lines[i]
+		}
+	}
+	return nil
+}
+
+var (
+	validKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	bareSafe = regexp.MustCompile(`^[A-Za-z0-9_./:@%+=,-]*$`)
+)
+
+// render formats an entry without a trailing newline, quoting the value when needed
+// $ and backticks are escaped in double quoted values so that loaders which expand them leave the value intact.
+func render(l *line) string {
+	var b strings.Builder
+	if l.export {
+		b.WriteString("export ")
+	}
+	b.WriteString(l.key)
+	b.WriteByte('=')
+
+	if bareSafe.MatchString(l.value) {
+		b.WriteString(l.value)
+	} else {
+		b.WriteByte('"')
+		for _, r := range l.value {
+			switch r {
+			case '\\':
+				b.WriteString(`\\`)
+			case '"':
+				b.WriteString(`\"`)
+			case '$':
+				b.WriteString(`\$`)
+			case '`':
+				b.WriteString("\\`")
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			case '\t':
+				b.WriteString(`\t`)
+			default:
+				b.WriteRune(r)
+			}
+		}
+		b.WriteByte('"')
+	}
+
+	if l.comment != "" {
+		b.WriteString(" ")
+		b.WriteString(l.comment)
+	}
+	return b.String()
+}
@@ -0,0 +1,195 @@
+package dotenvsecret_test
+
+import (
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/dotenvsecret"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const document = `# database settings
+DB_HOST=db.internal
+DB_PORT = 5432 # default port
+export DB_PASSWORD="old\"password"
+
+# api
+API_KEY='literal $value \n'
+CERT="-----BEGIN CERT-----
+abc
+-----END CERT-----"
+EMPTY=
+`
+
+func TestParse(t *testing.T) {
+	t.Run("parses values", func(t *testing.T) {
+		env, err := dotenvsecret.Parse([]byte(document))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []string{"DB_HOST", "DB_PORT", "DB_PASSWORD", "API_KEY", "CERT", "EMPTY"}, env.Keys())
+		assert.Equal(t, map[string]string{
+			"DB_HOST":     "db.internal",
+			"DB_PORT":     "5432",
+			"DB_PASSWORD": `old"password`,
+			"API_KEY":     `literal $value \n`,
+			"CERT":        "-----BEGIN CERT-----\nabc\n-----END CERT-----",
+			"EMPTY":       "",
+		}, env.Map())
+	})
+
+	t.Run("escapes in double quoted values", func(t *testing.T) {
+		env, err := dotenvsecret.Parse([]byte(`VALUE="a\nb\tc\\d\$e"`))
+		if !assert.NoError(t, err) {
+			return
+		}
+		value, ok := env.Get("VALUE")
+		assert.True(t, ok)
+		assert.Equal(t, "a\nb\tc\\d$e", value)
+	})
+
+	t.Run("hash without preceding space is part of an unquoted value", func(t *testing.T) {
+		env, err := dotenvsecret.Parse([]byte("URL=https://example.com/#anchor\n"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		value, _ := env.Get("URL")
+		assert.Equal(t, "https://example.com/#anchor", value)
+	})
+
+	t.Run("later duplicate keys win", func(t *testing.T) {
+		env, err := dotenvsecret.Parse([]byte("A=1\nA=2\n"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		value, _ := env.Get("A")
+		assert.Equal(t, "2", value)
+	})
+
+	t.Run("malformed documents are rejected", func(t *testing.T) {
+		cases := map[string]string{
+			"missing equals":     "JUST_A_KEY\n",
+			"invalid key":        "1KEY=value\n",
+			"unterminated quote": "KEY=\"value\nOTHER=1\n",
+			"text after quote":   "KEY='value' trailing\n",
+		}
+		for name, doc := range cases {
+			t.Run(name, func(t *testing.T) {
+				_, err := dotenvsecret.Parse([]byte(doc))
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestEnv(t *testing.T) {
+	t.Run("unchanged documents serialize byte for byte", func(t *testing.T) {
+		secret, err := dotenvsecret.Parser().Parse(rotate.StringSecret(document))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		raw, err := secret.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, document, string(raw))
+		assert.False(t, secret.Binary())
+	})
+
+	t.Run("updating a key keeps order, comments and other entries", func(t *testing.T) {
+		env, err := dotenvsecret.Parse([]byte(document))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.NoError(t, env.Set("DB_PASSWORD", "new pass#1"))
+		assert.NoError(t, env.Set("DB_PORT", "6432"))
+
+		raw, err := env.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, `# database settings
+DB_HOST=db.internal
+DB_PORT=6432 # default port
+export DB_PASSWORD="new pass#1"
+
+# api
+API_KEY='literal $value \n'
+CERT="-----BEGIN CERT-----
+abc
+-----END CERT-----"
+EMPTY=
+`, string(raw))
+	})
+
+	t.Run("updated values survive a round trip", func(t *testing.T) {
+		env, err := dotenvsecret.Parse([]byte(document))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, env.Set("CERT", "line one\nline \"two\"\t\\end"))
+
+		raw, _ := env.Value()
+		again, err := dotenvsecret.Parse(raw)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, env.Map(), again.Map())
+	})
+
+	t.Run("set values are not expanded when parsed", func(t *testing.T) {
+		env, _ := dotenvsecret.Parse(nil)
+		assert.NoError(t, env.Set("PASSWORD", "pa$$word with `command` and $(subshell)"))
+		assert.NoError(t, env.Set("QUOTED", `it's "$HOME"`))
+
+		raw, _ := env.Value()
+		assert.Equal(t, "PASSWORD=\"pa\\$\\$word with \\`command\\` and \\$(subshell)\"\nQUOTED=\"it's \\\"\\$HOME\\\"\"\n", string(raw))
+		again, err := dotenvsecret.Parse(raw)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, env.Map(), again.Map())
+	})
+
+	t.Run("clones are independent of the original", func(t *testing.T) {
+		env, err := dotenvsecret.Parse([]byte("A=1\n"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		clone := env.Clone()
+		assert.NoError(t, clone.Set("A", "2"))
+		assert.NoError(t, clone.Set("B", "3"))
+
+		raw, _ := env.Value()
+		assert.Equal(t, "A=1\n", string(raw))
+		raw, _ = clone.Value()
+		assert.Equal(t, "A=2\nB=3\n", string(raw))
+	})
+
+	t.Run("new keys are appended", func(t *testing.T) {
+		env, err := dotenvsecret.Parse([]byte("A=1"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, env.Set("B", "2"))
+
+		raw, _ := env.Value()
+		assert.Equal(t, "A=1\nB=2\n", string(raw))
+	})
+
+	t.Run("delete removes every entry for a key", func(t *testing.T) {
+		env, err := dotenvsecret.Parse([]byte("A=1\n# keep\nB=2\nA=3\n"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		env.Delete("A")
+
+		raw, _ := env.Value()
+		assert.Equal(t, "# keep\nB=2\n", string(raw))
+		_, ok := env.Get("A")
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid keys cannot be set", func(t *testing.T) {
+		env, _ := dotenvsecret.Parse(nil)
+		assert.Error(t, env.Set("NOT VALID", "x"))
+	})
+}
@@ -0,0 +1,140 @@
+package dotenvsecret
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse reads a dotenv document
+// Each entry is `KEY=VALUE`, optionally prefixed with `export`. Values may be:
+//
+//	unquoted       KEY=value # trailing comments are removed
+//	single quoted  KEY='taken literally, may span lines'
+//	double quoted  KEY="supports \n \r \t \" \\ \$ and \` escapes, may span lines"
+//
+// Lines that are blank or start with # are comments.
+func Parse(data []byte) (*Env, error) {
+	text := string(data)
+	env := &Env{}
+
+	for offset := 0; offset < len(text); {
+		end := lineEnd(text, offset)
+		trimmed := strings.TrimSpace(text[offset:end])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			env.lines = append(env.lines, &line{raw: text[offset:next(text, end)]})
+			offset = next(text, end)
+			continue
+		}
+
+		l, n, err := parseEntry(text[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("dotenvsecret: line %d: %w", strings.Count(text[:offset], "\n")+1, err)
+		}
+		l.raw = text[offset : offset+n]
+		env.lines = append(env.lines, l)
+		offset += n
+	}
+	return env, nil
+}
+
+// lineEnd returns the index of the newline ending the line that starts at offset, or the end of text
+func lineEnd(text string, offset int) int {
+	if idx := strings.IndexByte(text[offset:], '\n'); idx >= 0 {
+		return offset + idx
+	}
+	return len(text)
+}
+
+// next returns the offset following the newline at end
+func next(text string, end int) int {
+	if end < len(text) {
+		return end + 1
+	}
+	return end
+}
+
+// parseEntry parses a single entry at the start of text, returning the number of bytes it spans
+func parseEntry(text string) (*line, int, error) {
+	l := &line{entry: true}
+	pos := skipBlank(text, 0)
+
+	if strings.HasPrefix(text[pos:], "export") && pos+6 < len(text) && (text[pos+6] == ' ' || text[pos+6] == '\t') {
+		l.export = true
+		pos = skipBlank(text, pos+6)
+	}
+
+	eq := strings.IndexAny(text[pos:], "=\n")
+	if eq < 0 || text[pos+eq] != '=' {
+		return nil, 0, fmt.Errorf("expected KEY=VALUE")
+	}
+	l.key = strings.TrimSpace(text[pos : pos+eq])
+	if !validKey.MatchString(l.key) {
+		return nil, 0, fmt.Errorf("invalid key %q", l.key)
+	}
+	pos = skipBlank(text, pos+eq+1)
+
+	var err error
+	if pos < len(text) && (text[pos] == '"' || text[pos] == '\'') {
+		if l.value, pos, err = quoted(text, pos); err != nil {
+			return nil, 0, err
+		}
+
+		end := lineEnd(text, pos)
+		rest := strings.TrimSpace(text[pos:end])
+		if rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, 0, fmt.Errorf("unexpected %q after quoted value of %s", rest, l.key)
+		}
+		l.comment = rest
+		return l, next(text, end), nil
+	}
+
+	end := lineEnd(text, pos)
+	value := strings.TrimSuffix(text[pos:end], "\r")
+	for i := 0; i < len(value); i++ {
+		if value[i] == '#' && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t') {
+			l.comment = strings.TrimSpace(value[i:])
+			value = value[:i]
+			break
+		}
+	}
+	l.value = strings.TrimSpace(value)
+	return l, next(text, end), nil
+}
+
+// quoted reads a quoted value starting at the opening quote, returning the value and the offset after the closing quote
+func quoted(text string, pos int) (string, int, error) {
+	quote := text[pos]
+	var b strings.Builder
+	for i := pos + 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && quote == '"' && i+1 < len(text):
+			i++
+			switch text[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$', '`':
+				b.WriteByte(text[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(text[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated %c quoted value", quote)
+}
+
+func skipBlank(text string, pos int) int {
+	for pos < len(text) && (text[pos] == ' ' || text[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
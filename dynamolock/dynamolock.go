@@ -0,0 +1,135 @@
+// Package dynamolock leases secrets to a single rotation using conditional writes to a DynamoDB table
+// The table must have a string partition key named SecretId. Leases record their expiry as epoch seconds in the
+// Expires attribute, which can be enabled as the table's TTL attribute so that abandoned leases are removed.
+package dynamolock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"strconv"
+	"time"
+)
+
+const (
+	KeyAttribute     = "SecretId"
+	OwnerAttribute   = "Owner"
+	ExpiresAttribute = "Expires"
+)
+
+// DynamoDBApi is the subset of the DynamoDB client used to manage leases
+type DynamoDBApi interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+type Config struct {
+	DynamoDB DynamoDBApi
+
+	// Table stores a lease for each secret being rotated
+	Table string
+
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// Locker is a rotate.Locker shared by every process rotating secrets against the same table
+type Locker struct {
+	client DynamoDBApi
+	table  string
+	now    func() time.Time
+}
+
+func New(c Config) *Locker {
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return &Locker{client: c.DynamoDB, table: c.Table, now: c.Now}
+}
+
+// Lock writes the lease for owner unless another owner holds a lease that has not expired
+func (l *Locker) Lock(ctx context.Context, secretId, owner string, ttl time.Duration) error {
+	now := l.now()
+	condition := "attribute_not_exists(#key) OR #owner = :owner OR #expires <= :now"
+	_, err := l.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &l.table,
+		Item: map[string]types.AttributeValue{
+			KeyAttribute:     &types.AttributeValueMemberS{Value: secretId},
+			OwnerAttribute:   &types.AttributeValueMemberS{Value: owner},
+			ExpiresAttribute: epochSeconds(now.Add(ttl)),
+		},
+		ConditionExpression: &condition,
+		ExpressionAttributeNames: map[string]string{
+			"#key":     KeyAttribute,
+			"#owner":   OwnerAttribute,
+			"#expires": ExpiresAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: owner},
+			":now":   epochSeconds(now),
+		},
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return l.locked(ctx, secretId)
+	}
+	return err
+}
+
+// Unlock deletes the lease when it is held by owner
+func (l *Locker) Unlock(ctx context.Context, secretId, owner string) error {
+	condition := "#owner = :owner"
+	_, err := l.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                &l.table,
+		Key:                      key(secretId),
+		ConditionExpression:      &condition,
+		ExpressionAttributeNames: map[string]string{"#owner": OwnerAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: owner},
+		},
+	})
+
+	// the lease has already expired and been taken by another owner
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return nil
+	}
+	return err
+}
+
+// locked reads the lease that prevented a Lock, to describe its owner
+func (l *Locker) locked(ctx context.Context, secretId string) error {
+	consistent := true
+	output, err := l.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &l.table,
+		Key:            key(secretId),
+		ConsistentRead: &consistent,
+	})
+	if err != nil {
+		return errors.Join(&rotate.LockedError{SecretId: secretId}, fmt.Errorf("reading lease: %w", err))
+	}
+
+	lockedErr := &rotate.LockedError{SecretId: secretId}
+	if owner, ok := output.Item[OwnerAttribute].(*types.AttributeValueMemberS); ok {
+		lockedErr.Owner = owner.Value
+	}
+	if expires, ok := output.Item[ExpiresAttribute].(*types.AttributeValueMemberN); ok {
+		if seconds, err := strconv.ParseInt(expires.Value, 10, 64); err == nil {
+			lockedErr.Expires = time.Unix(seconds, 0)
+		}
+	}
+	return lockedErr
+}
+
+func key(secretId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{KeyAttribute: &types.AttributeValueMemberS{Value: secretId}}
+}
+
+func epochSeconds(t time.Time) *types.AttributeValueMemberN {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+}
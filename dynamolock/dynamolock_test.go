@@ -0,0 +1,102 @@
+package dynamolock
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLocker(t *testing.T) {
+	table := &fakeTable{items: map[string]map[string]types.AttributeValue{}}
+	now := time.Unix(1700000000, 0)
+	var locker rotate.Locker = New(Config{DynamoDB: table, Table: "rotation-leases", Now: func() time.Time { return now }})
+	ctx := context.TODO()
+
+	assert.NoError(t, locker.Lock(ctx, "my-secret", "scheduled", time.Minute))
+	assert.Equal(t, "1700000060", table.items["my-secret"][ExpiresAttribute].(*types.AttributeValueMemberN).Value)
+	assert.NoError(t, locker.Lock(ctx, "my-secret", "scheduled", time.Minute), "the owner should renew its lease")
+
+	err := locker.Lock(ctx, "my-secret", "manual", time.Minute)
+	var locked *rotate.LockedError
+	if assert.ErrorAs(t, err, &locked) {
+		assert.Equal(t, "scheduled", locked.Owner)
+		assert.Equal(t, now.Add(time.Minute), locked.Expires)
+	}
+
+	assert.NoError(t, locker.Unlock(ctx, "my-secret", "manual"), "releasing another owner's lease should do nothing")
+	assert.Error(t, locker.Lock(ctx, "my-secret", "manual", time.Minute))
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, locker.Lock(ctx, "my-secret", "manual", time.Minute), "an expired lease should be taken over")
+	assert.NoError(t, locker.Unlock(ctx, "my-secret", "manual"))
+	assert.Empty(t, table.items)
+}
+
+func TestLockerErrors(t *testing.T) {
+	unavailable := errors.New("service unavailable")
+	table := &fakeTable{items: map[string]map[string]types.AttributeValue{}}
+	locker := New(Config{DynamoDB: table, Table: "rotation-leases"})
+	ctx := context.TODO()
+
+	assert.NoError(t, locker.Lock(ctx, "my-secret", "scheduled", time.Minute))
+	table.getErr = unavailable
+	err := locker.Lock(ctx, "my-secret", "manual", time.Minute)
+	var locked *rotate.LockedError
+	assert.ErrorAs(t, err, &locked, "should still report the secret is locked")
+	assert.ErrorIs(t, err, unavailable)
+
+	table.putErr = unavailable
+	assert.ErrorIs(t, locker.Lock(ctx, "other-secret", "manual", time.Minute), unavailable)
+}
+
+// fakeTable evaluates the condition expressions used by Locker
+type fakeTable struct {
+	items  map[string]map[string]types.AttributeValue
+	putErr error
+	getErr error
+}
+
+func (f *fakeTable) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if f.putErr != nil {
+		return nil, f.putErr
+	}
+	secretId := params.Item[KeyAttribute].(*types.AttributeValueMemberS).Value
+	if existing, ok := f.items[secretId]; ok {
+		owner := params.ExpressionAttributeValues[":owner"].(*types.AttributeValueMemberS).Value
+		now := number(params.ExpressionAttributeValues[":now"])
+		if existing[OwnerAttribute].(*types.AttributeValueMemberS).Value != owner && number(existing[ExpiresAttribute]) > now {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	}
+	f.items[secretId] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeTable) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	return &dynamodb.GetItemOutput{Item: f.items[params.Key[KeyAttribute].(*types.AttributeValueMemberS).Value]}, nil
+}
+
+func (f *fakeTable) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	secretId := params.Key[KeyAttribute].(*types.AttributeValueMemberS).Value
+	owner := params.ExpressionAttributeValues[":owner"].(*types.AttributeValueMemberS).Value
+	existing, ok := f.items[secretId]
+	if !ok || existing[OwnerAttribute].(*types.AttributeValueMemberS).Value != owner {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	delete(f.items, secretId)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func number(value types.AttributeValue) int64 {
+	n, _ := strconv.ParseInt(value.(*types.AttributeValueMemberN).Value, 10, 64)
+	return n
+}
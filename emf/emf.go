@@ -0,0 +1,160 @@
+// Package emf records rotation metrics in the CloudWatch Embedded Metric Format
+// Each measurement is written as a single JSON line, which CloudWatch Logs extracts into metrics when written to the
+// stdout of a Lambda function, so no calls to CloudWatch are needed.
+package emf
+
+import (
+	"encoding/json"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Writer receives each metric document, defaults to os.Stdout
+	Writer io.Writer
+
+	// Namespace is the CloudWatch namespace metrics are recorded under, defaults to "SecretsManagerRotation"
+	Namespace string
+
+	// Now returns the timestamp of each document, defaults to time.Now
+	Now func() time.Time
+}
+
+// Metric names
+const (
+	StepSuccess     = "StepSuccess"
+	StepFailure     = "StepFailure"
+	StepSkipped     = "StepSkipped"
+	StepDuration    = "StepDuration"
+	HookError       = "HookError"
+	HookDuration    = "HookDuration"
+	APICallError    = "APICallError"
+	APICallDuration = "APICallDuration"
+)
+
+// Dimension names
+const (
+	SecretIdDimension  = "SecretId"
+	StepDimension      = "Step"
+	OperationDimension = "Operation"
+)
+
+// Metrics returns a rotate.Metrics that writes each measurement as an EMF document
+func Metrics(c Config) rotate.Metrics {
+	if c.Writer == nil {
+		c.Writer = os.Stdout
+	}
+	if c.Namespace == "" {
+		c.Namespace = "SecretsManagerRotation"
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return &metrics{writer: c.Writer, namespace: c.Namespace, now: c.Now}
+}
+
+type metrics struct {
+	mu        sync.Mutex
+	writer    io.Writer
+	namespace string
+	now       func() time.Time
+}
+
+func (m *metrics) StepCompleted(event rotate.Event, outcome rotate.Outcome, duration time.Duration) {
+	values := map[string]interface{}{
+		StepSuccess:  count(outcome == rotate.OutcomeSuccess),
+		StepFailure:  count(outcome == rotate.OutcomeFailure),
+		StepSkipped:  count(outcome == rotate.OutcomeSkipped),
+		StepDuration: milliseconds(duration),
+	}
+	m.write(
+		[]string{SecretIdDimension, StepDimension},
+		map[string]string{SecretIdDimension: event.SecretId, StepDimension: string(event.Step)},
+		[]metric{{StepSuccess, "Count"}, {StepFailure, "Count"}, {StepSkipped, "Count"}, {StepDuration, "Milliseconds"}},
+		values,
+		map[string]interface{}{"Outcome": outcome, "ClientRequestToken": event.ClientRequestToken},
+	)
+}
+
+func (m *metrics) HookCompleted(event rotate.Event, err error, duration time.Duration) {
+	m.write(
+		[]string{SecretIdDimension, StepDimension},
+		map[string]string{SecretIdDimension: event.SecretId, StepDimension: string(event.Step)},
+		[]metric{{HookError, "Count"}, {HookDuration, "Milliseconds"}},
+		map[string]interface{}{HookError: count(err != nil), HookDuration: milliseconds(duration)},
+		nil,
+	)
+}
+
+func (m *metrics) APICallCompleted(secretId string, operation string, err error, duration time.Duration) {
+	m.write(
+		[]string{SecretIdDimension, OperationDimension},
+		map[string]string{SecretIdDimension: secretId, OperationDimension: operation},
+		[]metric{{APICallError, "Count"}, {APICallDuration, "Milliseconds"}},
+		map[string]interface{}{APICallError: count(err != nil), APICallDuration: milliseconds(duration)},
+		nil,
+	)
+}
+
+type metric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+type directive struct {
+	Namespace  string     `json:"Namespace"`
+	Dimensions [][]string `json:"Dimensions"`
+	Metrics    []metric   `json:"Metrics"`
+}
+
+type metadata struct {
+	Timestamp         int64       `json:"Timestamp"`
+	CloudWatchMetrics []directive `json:"CloudWatchMetrics"`
+}
+
+// write encodes a single document, dimensions are recorded both together and with only the last dimension so that
+// each metric can be graphed per secret as well as across every secret
+func (m *metrics) write(dimensions []string, dimensionValues map[string]string, definitions []metric, values map[string]interface{}, properties map[string]interface{}) {
+	doc := make(map[string]interface{}, len(dimensionValues)+len(values)+len(properties)+1)
+	for k, v := range properties {
+		doc[k] = v
+	}
+	for k, v := range dimensionValues {
+		doc[k] = v
+	}
+	for k, v := range values {
+		doc[k] = v
+	}
+	doc["_aws"] = metadata{
+		Timestamp: m.now().UnixMilli(),
+		CloudWatchMetrics: []directive{{
+			Namespace:  m.namespace,
+			Dimensions: [][]string{dimensions, dimensions[len(dimensions)-1:]},
+			Metrics:    definitions,
+		}},
+	}
+
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _ = m.writer.Write(line)
+}
+
+func count(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
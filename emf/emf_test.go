@@ -0,0 +1,86 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	event := rotate.Event{SecretId: "my-secret", ClientRequestToken: "version-2", Step: rotate.StepCreate}
+
+	t.Run("step", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		m := Metrics(Config{Writer: buf, Namespace: "Test", Now: func() time.Time { return now }})
+		m.StepCompleted(event, rotate.OutcomeSkipped, 1500*time.Microsecond)
+
+		docs := decode(t, buf)
+		if !assert.Len(t, docs, 1) {
+			return
+		}
+		doc := docs[0]
+		assert.Equal(t, "my-secret", doc[SecretIdDimension])
+		assert.Equal(t, "createSecret", doc[StepDimension])
+		assert.Equal(t, "skipped", doc["Outcome"])
+		assert.Equal(t, 0.0, doc[StepSuccess])
+		assert.Equal(t, 0.0, doc[StepFailure])
+		assert.Equal(t, 1.0, doc[StepSkipped])
+		assert.Equal(t, 1.5, doc[StepDuration])
+
+		aws := doc["_aws"].(map[string]interface{})
+		assert.Equal(t, float64(now.UnixMilli()), aws["Timestamp"])
+		directive := aws["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "Test", directive["Namespace"])
+		assert.Equal(t, []interface{}{[]interface{}{"SecretId", "Step"}, []interface{}{"Step"}}, directive["Dimensions"])
+		assert.Contains(t, directive["Metrics"], map[string]interface{}{"Name": StepDuration, "Unit": "Milliseconds"})
+		assert.Contains(t, directive["Metrics"], map[string]interface{}{"Name": StepSkipped, "Unit": "Count"})
+	})
+
+	t.Run("hook", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		m := Metrics(Config{Writer: buf})
+		m.HookCompleted(event, errors.New("failed"), 2*time.Millisecond)
+		m.HookCompleted(event, nil, time.Millisecond)
+
+		docs := decode(t, buf)
+		if assert.Len(t, docs, 2) {
+			assert.Equal(t, 1.0, docs[0][HookError])
+			assert.Equal(t, 2.0, docs[0][HookDuration])
+			assert.Equal(t, 0.0, docs[1][HookError])
+
+			directive := docs[0]["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+			assert.Equal(t, "SecretsManagerRotation", directive["Namespace"])
+		}
+	})
+
+	t.Run("api call", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		m := Metrics(Config{Writer: buf})
+		m.APICallCompleted("my-secret", "PutSecretValue", nil, 3*time.Millisecond)
+
+		docs := decode(t, buf)
+		if assert.Len(t, docs, 1) {
+			assert.Equal(t, "PutSecretValue", docs[0][OperationDimension])
+			assert.Equal(t, 0.0, docs[0][APICallError])
+			assert.Equal(t, 3.0, docs[0][APICallDuration])
+		}
+	})
+}
+
+func decode(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var docs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var doc map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal([]byte(line), &doc)) {
+			return nil
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
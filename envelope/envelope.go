@@ -0,0 +1,161 @@
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"io"
+)
+
+// KeyWrapper protects the data keys that encrypt secret payloads
+type KeyWrapper interface {
+	// WrapKey encrypts a plaintext data key
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+
+	// UnwrapKey decrypts a data key previously returned by WrapKey
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// document is the JSON envelope stored in Secrets Manager
+type document struct {
+	Version int `json:"v"`
+
+	// Key is the data key, wrapped by the KeyWrapper
+	Key []byte `json:"key"`
+
+	// Nonce is the AES-GCM nonce used to seal Data
+	Nonce []byte `json:"nonce"`
+
+	// Data is the encrypted secret payload
+	Data []byte `json:"data"`
+
+	// Binary records whether the plaintext was a binary secret, so it is restored in the same form
+	Binary bool `json:"binary,omitempty"`
+}
+
+const (
+	envelopeVersion = 1
+	dataKeyLength   = 32
+)
+
+// Codec returns a rotate.SecretCodec that decrypts enveloped secrets when parsing and encrypts them when encoding
+// Each encoding generates a new AES-256-GCM data key which is stored alongside the payload, wrapped by wrapper.
+// SecretParser has no context, so calls to wrapper are made with context.Background().
+func Codec(wrapper KeyWrapper) rotate.SecretCodec {
+	return &codec{wrapper: wrapper, random: rand.Reader}
+}
+
+type codec struct {
+	wrapper KeyWrapper
+	random  io.Reader
+}
+
+func (c *codec) Parse(secret rotate.Secret) (rotate.Secret, error) {
+	data, err := secret.Value()
+	if err != nil {
+		return secret, err
+	}
+
+	var doc document
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return secret, fmt.Errorf("envelope: %w", err)
+	}
+	if doc.Version != envelopeVersion {
+		return secret, fmt.Errorf("envelope: unsupported version %d", doc.Version)
+	}
+
+	key, err := c.wrapper.UnwrapKey(context.Background(), doc.Key)
+	if err != nil {
+		return secret, fmt.Errorf("envelope: unwrapping data key: %w", err)
+	}
+	defer zero(key)
+
+	plaintext, err := open(key, doc.Nonce, doc.Data, doc.Key)
+	if err != nil {
+		return secret, fmt.Errorf("envelope: %w", err)
+	}
+
+	if doc.Binary {
+		return rotate.BinarySecret(plaintext), nil
+	}
+	return rotate.StringSecret(plaintext), nil
+}
+
+func (c *codec) Encode(secret rotate.Secret) (rotate.Secret, error) {
+	plaintext, err := secret.Value()
+	if err != nil {
+		return secret, err
+	}
+
+	key := make([]byte, dataKeyLength)
+	defer zero(key)
+	if _, err = io.ReadFull(c.random, key); err != nil {
+		return secret, fmt.Errorf("envelope: generating data key: %w", err)
+	}
+
+	wrapped, err := c.wrapper.WrapKey(context.Background(), key)
+	if err != nil {
+		return secret, fmt.Errorf("envelope: wrapping data key: %w", err)
+	}
+
+	nonce, sealed, err := seal(c.random, key, plaintext, wrapped)
+	if err != nil {
+		return secret, fmt.Errorf("envelope: %w", err)
+	}
+
+	data, err := json.Marshal(&document{
+		Version: envelopeVersion,
+		Key:     wrapped,
+		Nonce:   nonce,
+		Data:    sealed,
+		Binary:  secret.Binary(),
+	})
+	if err != nil {
+		return secret, err
+	}
+	return rotate.StringSecret(data), nil
+}
+
+// seal encrypts plaintext with AES-GCM, additional data is authenticated but not encrypted
+func seal(random io.Reader, key, plaintext, additional []byte) (nonce []byte, sealed []byte, err error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(random, nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, plaintext, additional), nil
+}
+
+func open(key, nonce, sealed, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	return aead.Open(nil, nonce, sealed, additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,126 @@
+package envelope_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/envelope"
+	"github.com/printerlogic/go-secretsmanager-rotate/jsonsecret"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	wrapper, err := envelope.LocalWrapper(bytes.Repeat([]byte{1}, 32))
+	if !assert.NoError(t, err) {
+		return
+	}
+	codec := envelope.Codec(wrapper)
+
+	t.Run("string secrets round trip", func(t *testing.T) {
+		encoded, err := codec.Encode(rotate.StringSecret(`{"password": "hunter2"}`))
+		if !assert.NoError(t, err) {
+			return
+		}
+		raw, _ := encoded.Value()
+		assert.False(t, encoded.Binary())
+		assert.NotContains(t, string(raw), "hunter2")
+
+		decoded, err := codec.Parse(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, rotate.StringSecret(`{"password": "hunter2"}`), decoded)
+	})
+
+	t.Run("binary secrets round trip as binary", func(t *testing.T) {
+		encoded, err := codec.Encode(rotate.BinarySecret{0, 1, 2, 3})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		decoded, err := codec.Parse(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, rotate.BinarySecret{0, 1, 2, 3}, decoded)
+	})
+
+	t.Run("every encoding uses a new data key", func(t *testing.T) {
+		first, err := codec.Encode(rotate.StringSecret("hunter2"))
+		assert.NoError(t, err)
+		second, err := codec.Encode(rotate.StringSecret("hunter2"))
+		assert.NoError(t, err)
+		assert.NotEqual(t, envelopeOf(t, first)["key"], envelopeOf(t, second)["key"])
+	})
+
+	t.Run("tampered payloads are rejected", func(t *testing.T) {
+		encoded, err := codec.Encode(rotate.StringSecret("hunter2"))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		doc := envelopeOf(t, encoded)
+		data := doc["data"].([]byte)
+		data[0] ^= 0xff
+		doc["data"] = data
+		tampered, _ := json.Marshal(doc)
+
+		_, err = codec.Parse(rotate.StringSecret(tampered))
+		assert.Error(t, err)
+	})
+
+	t.Run("payloads wrapped by another key are rejected", func(t *testing.T) {
+		other, err := envelope.LocalWrapper(bytes.Repeat([]byte{2}, 32))
+		if !assert.NoError(t, err) {
+			return
+		}
+		encoded, err := envelope.Codec(other).Encode(rotate.StringSecret("hunter2"))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = codec.Parse(encoded)
+		assert.Error(t, err)
+	})
+
+	t.Run("non envelope documents are rejected", func(t *testing.T) {
+		_, err := codec.Parse(rotate.StringSecret(`{"password": "hunter2"}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("chains with struct parsers", func(t *testing.T) {
+		layers := rotate.Chain(codec, jsonsecret.Parser(&Credentials{}))
+		encoded, err := layers.Encode(&Credentials{Password: "hunter2"})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		parsed, err := layers.Parse(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, &Credentials{Password: "hunter2"}, parsed)
+	})
+}
+
+// envelopeOf decodes the stored envelope, with base64 fields decoded to bytes
+func envelopeOf(t *testing.T, secret rotate.Secret) map[string]interface{} {
+	raw, err := secret.Value()
+	assert.NoError(t, err)
+
+	var doc struct {
+		Version int    `json:"v"`
+		Key     []byte `json:"key"`
+		Nonce   []byte `json:"nonce"`
+		Data    []byte `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(raw, &doc))
+	return map[string]interface{}{"v": doc.Version, "key": doc.Key, "nonce": doc.Nonce, "data": doc.Data}
+}
+
+type Credentials struct {
+	Password string `json:"password"`
+}
+
+func (c *Credentials) Binary() bool {
+	return false
+}
+
+func (c *Credentials) Value() ([]byte, error) {
+	return jsonsecret.Marshal(c)
+}
@@ -0,0 +1,115 @@
+package envelope
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"io"
+	"time"
+)
+
+// LocalWrapper returns a KeyWrapper that wraps data keys with AES-GCM under a key held in memory
+// The key must be 16, 24 or 32 bytes long. It is intended for tests and local development.
+func LocalWrapper(key []byte) (KeyWrapper, error) {
+	if _, err := newGCM(key); err != nil {
+		return nil, fmt.Errorf("envelope: %w", err)
+	}
+	return &localWrapper{key: append([]byte(nil), key...), random: rand.Reader}, nil
+}
+
+type localWrapper struct {
+	key    []byte
+	random io.Reader
+}
+
+// WrapKey returns the nonce followed by the sealed data key
+func (l *localWrapper) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	nonce, sealed, err := seal(l.random, l.key, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, sealed...), nil
+}
+
+func (l *localWrapper) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	aead, err := newGCM(l.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+	return open(l.key, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+}
+
+// KMSApi is the subset of the KMS client used to wrap data keys
+type KMSApi interface {
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+type KMSConfig struct {
+	// KMS performs the encryption and decryption of data keys
+	KMS KMSApi
+
+	// KeyId is the KMS key that wraps data keys, as a key id, ARN or alias
+	KeyId string
+
+	// EncryptionContext is bound to every wrapped key and must match when unwrapping
+	EncryptionContext map[string]string
+
+	// Timeout limits each call to KMS, defaults to 5 seconds
+	Timeout time.Duration
+}
+
+// KMSWrapper returns a KeyWrapper that wraps data keys with a KMS key
+func KMSWrapper(c KMSConfig) KeyWrapper {
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	return &kmsWrapper{
+		api:     c.KMS,
+		keyId:   c.KeyId,
+		context: c.EncryptionContext,
+		timeout: c.Timeout,
+	}
+}
+
+type kmsWrapper struct {
+	api     KMSApi
+	keyId   string
+	context map[string]string
+	timeout time.Duration
+}
+
+func (k *kmsWrapper) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+
+	output, err := k.api.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             &k.keyId,
+		Plaintext:         key,
+		EncryptionContext: k.context,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.CiphertextBlob, nil
+}
+
+func (k *kmsWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+
+	output, err := k.api.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             &k.keyId,
+		CiphertextBlob:    wrapped,
+		EncryptionContext: k.context,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
@@ -0,0 +1,110 @@
+package envelope_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/envelope"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+func TestLocalWrapper(t *testing.T) {
+	t.Run("rejects invalid key sizes", func(t *testing.T) {
+		_, err := envelope.LocalWrapper([]byte("too short"))
+		assert.Error(t, err)
+	})
+
+	t.Run("unwraps what it wrapped", func(t *testing.T) {
+		wrapper, err := envelope.LocalWrapper(bytes.Repeat([]byte{1}, 16))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		wrapped, err := wrapper.WrapKey(context.TODO(), []byte("data key"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NotContains(t, string(wrapped), "data key")
+
+		key, err := wrapper.UnwrapKey(context.TODO(), wrapped)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("data key"), key)
+
+		_, err = wrapper.UnwrapKey(context.TODO(), wrapped[:4])
+		assert.Error(t, err)
+	})
+}
+
+func TestKMSWrapper(t *testing.T) {
+	api := newLocalKMS(t, "alias/secrets")
+	wrapper := envelope.KMSWrapper(envelope.KMSConfig{
+		KMS:               api,
+		KeyId:             "alias/secrets",
+		EncryptionContext: map[string]string{"purpose": "rotation"},
+	})
+
+	t.Run("wraps data keys with the configured key and context", func(t *testing.T) {
+		encoded, err := envelope.Codec(wrapper).Encode(rotate.StringSecret("hunter2"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		decoded, err := envelope.Codec(wrapper).Parse(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, rotate.StringSecret("hunter2"), decoded)
+		assert.Equal(t, 1, api.encrypts)
+		assert.Equal(t, 1, api.decrypts)
+	})
+
+	t.Run("fails when the encryption context differs", func(t *testing.T) {
+		encoded, err := envelope.Codec(wrapper).Encode(rotate.StringSecret("hunter2"))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		other := envelope.KMSWrapper(envelope.KMSConfig{
+			KMS:               api,
+			KeyId:             "alias/secrets",
+			EncryptionContext: map[string]string{"purpose": "other"},
+		})
+		_, err = envelope.Codec(other).Parse(encoded)
+		assert.Error(t, err)
+	})
+}
+
+// localKMS mimics KMS with a single symmetric key backed by a LocalWrapper
+type localKMS struct {
+	keyId    string
+	wrapper  envelope.KeyWrapper
+	contexts map[string]map[string]string
+	encrypts int
+	decrypts int
+}
+
+func newLocalKMS(t *testing.T, keyId string) *localKMS {
+	wrapper, err := envelope.LocalWrapper(bytes.Repeat([]byte{9}, 32))
+	assert.NoError(t, err)
+	return &localKMS{keyId: keyId, wrapper: wrapper, contexts: map[string]map[string]string{}}
+}
+
+func (l *localKMS) Encrypt(ctx context.Context, params *kms.EncryptInput, _ ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	if *params.KeyId != l.keyId {
+		return nil, errors.New("local: NotFoundException")
+	}
+	l.encrypts++
+	blob, err := l.wrapper.WrapKey(ctx, params.Plaintext)
+	l.contexts[string(blob)] = params.EncryptionContext
+	return &kms.EncryptOutput{CiphertextBlob: blob, KeyId: params.KeyId}, err
+}
+
+func (l *localKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	l.decrypts++
+	if !reflect.DeepEqual(l.contexts[string(params.CiphertextBlob)], params.EncryptionContext) {
+		return nil, errors.New("local: InvalidCiphertextException")
+	}
+	plaintext, err := l.wrapper.UnwrapKey(ctx, params.CiphertextBlob)
+	return &kms.DecryptOutput{Plaintext: plaintext, KeyId: params.KeyId}, err
+}
@@ -0,0 +1,154 @@
+package rotate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SecretVersion describes the version of a secret loaded from Secrets Manager
+type SecretVersion struct {
+	VersionId     string
+	VersionStages []string
+
+	// CreatedDate is when the version was created, it is zero when Secrets Manager did not report it
+	CreatedDate time.Time
+}
+
+// FreshnessPolicy decides whether the current version of a secret is due for rotation
+type FreshnessPolicy interface {
+	// ShouldRotate reports whether current should be replaced, with a reason describing the decision
+	ShouldRotate(ctx context.Context, current Secret, version SecretVersion) (rotate bool, reason string, err error)
+}
+
+// FreshnessPolicyFunc adapts an ordinary function to a FreshnessPolicy
+type FreshnessPolicyFunc func(ctx context.Context, current Secret, version SecretVersion) (bool, string, error)
+
+func (f FreshnessPolicyFunc) ShouldRotate(ctx context.Context, current Secret, version SecretVersion) (bool, string, error) {
+	return f(ctx, current, version)
+}
+
+// FreshnessService is a Service that only wants to create a new version once the current one is due for rotation
+// When the current version is still fresh the create step completes without creating a version, and the remaining
+// steps have nothing to do. A built-in FreshnessPolicy can be embedded in the Service to implement it, e.g.
+//
+//	type certificateService struct {
+//		rotate.FreshnessPolicy
+//	}
+//
+//	service := &certificateService{FreshnessPolicy: rotate.MinAge(30 * 24 * time.Hour)}
+type FreshnessService interface {
+	Service
+	FreshnessPolicy
+}
+
+// MinAge rotates the current version once it is at least age old, judged by its CreatedDate
+// A version without a CreatedDate is always rotated.
+func MinAge(age time.Duration) FreshnessPolicy {
+	return minAge{age: age, now: time.Now}
+}
+
+type minAge struct {
+	age time.Duration
+	now func() time.Time
+}
+
+func (m minAge) ShouldRotate(_ context.Context, _ Secret, version SecretVersion) (bool, string, error) {
+	if version.CreatedDate.IsZero() {
+		return true, "the creation date of the current version is unknown", nil
+	}
+	current := m.now().Sub(version.CreatedDate).Truncate(time.Second)
+	if current < m.age {
+		return false, fmt.Sprintf("the current version is %s old, younger than the minimum age of %s", current, m.age), nil
+	}
+	return true, fmt.Sprintf("the current version is %s old, at least the minimum age of %s", current, m.age), nil
+}
+
+// ExpiresWithin rotates the current version once the expiry returned by expiry is less than window away
+// expiry is given the parsed secret, see ExpiryField for secrets holding their expiry in a JSON field.
+func ExpiresWithin(window time.Duration, expiry func(Secret) (time.Time, error)) FreshnessPolicy {
+	return expiresWithin{window: window, expiry: expiry, now: time.Now}
+}
+
+type expiresWithin struct {
+	window time.Duration
+	expiry func(Secret) (time.Time, error)
+	now    func() time.Time
+}
+
+func (e expiresWithin) ShouldRotate(_ context.Context, current Secret, _ SecretVersion) (bool, string, error) {
+	expires, err := e.expiry(current)
+	if err != nil {
+		return false, "", fmt.Errorf("reading expiry of the current version: %w", err)
+	}
+	remaining := expires.Sub(e.now()).Truncate(time.Second)
+	if remaining > e.window {
+		return false, fmt.Sprintf("the current version expires in %s, beyond the rotation window of %s", remaining, e.window), nil
+	}
+	return true, fmt.Sprintf("the current version expires in %s, within the rotation window of %s", remaining, e.window), nil
+}
+
+// ExpiryField reads the expiry of a secret from a top level field of its JSON value, holding either an RFC 3339
+// timestamp or the number of seconds since the Unix epoch
+func ExpiryField(name string) func(Secret) (time.Time, error) {
+	return func(secret Secret) (time.Time, error) {
+		value, err := secret.Value()
+		if err != nil {
+			return time.Time{}, err
+		}
+		var fields map[string]json.RawMessage
+		if err = json.Unmarshal(value, &fields); err != nil {
+			return time.Time{}, fmt.Errorf("secret is not a JSON object")
+		}
+		raw, ok := fields[name]
+		if !ok {
+			return time.Time{}, fmt.Errorf("secret has no %s field", name)
+		}
+
+		var timestamp string
+		if err = json.Unmarshal(raw, &timestamp); err == nil {
+			expires, err := time.Parse(time.RFC3339, timestamp)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("field %s is not an RFC 3339 timestamp", name)
+			}
+			return expires, nil
+		}
+		var seconds int64
+		if err = json.Unmarshal(raw, &seconds); err != nil {
+			return time.Time{}, fmt.Errorf("field %s must be an RFC 3339 timestamp or Unix time in seconds", name)
+		}
+		return time.Unix(seconds, 0), nil
+	}
+}
+
+// fresh reports whether the current version does not need to be rotated yet, skipping the step when it does not
+func (r *rotator) fresh(ctx context.Context, current Secret, version SecretVersion) (bool, error) {
+	policy, ok := r.service.(FreshnessService)
+	if !ok || r.ignoreFreshness {
+		return false, nil
+	}
+	rotate, reason, err := policy.ShouldRotate(ctx, current, version)
+	if err != nil {
+		return false, err
+	}
+	if rotate {
+		r.logger.Println("Rotating because " + reason)
+		return false, nil
+	}
+	r.skip(ctx, "Not rotating because "+reason)
+	return true, nil
+}
+
+// currentFresh reports whether the current version of the secret does not need to be rotated yet, skipping the step
+// when it does not
+func (r *rotator) currentFresh(ctx context.Context, event Event) (bool, error) {
+	if _, ok := r.service.(FreshnessService); !ok || r.ignoreFreshness {
+		return false, nil
+	}
+	version, current, err := r.getSecret(ctx, event.SecretId, AWSCURRENT)
+	if err != nil {
+		return false, err
+	}
+	return r.fresh(ctx, current, version)
+}
@@ -0,0 +1,141 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMinAge(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	policy := minAge{age: 30 * 24 * time.Hour, now: func() time.Time { return now }}
+
+	rotate, reason, err := policy.ShouldRotate(context.TODO(), StringSecret("value"), SecretVersion{CreatedDate: now.Add(-24 * time.Hour)})
+	assert.NoError(t, err)
+	assert.False(t, rotate)
+	assert.Equal(t, "the current version is 24h0m0s old, younger than the minimum age of 720h0m0s", reason)
+
+	rotate, _, err = policy.ShouldRotate(context.TODO(), StringSecret("value"), SecretVersion{CreatedDate: now.Add(-30 * 24 * time.Hour)})
+	assert.NoError(t, err)
+	assert.True(t, rotate)
+
+	rotate, _, err = policy.ShouldRotate(context.TODO(), StringSecret("value"), SecretVersion{})
+	assert.NoError(t, err)
+	assert.True(t, rotate, "a version of unknown age should be rotated")
+}
+
+func TestExpiresWithin(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	policy := expiresWithin{window: 7 * 24 * time.Hour, expiry: ExpiryField("notAfter"), now: func() time.Time { return now }}
+	shouldRotate := func(value string) (bool, error) {
+		rotate, _, err := policy.ShouldRotate(context.TODO(), StringSecret(value), SecretVersion{})
+		return rotate, err
+	}
+
+	rotate, err := shouldRotate(`{"notAfter": "2024-06-01T00:00:00Z"}`)
+	assert.NoError(t, err)
+	assert.False(t, rotate)
+
+	rotate, err = shouldRotate(`{"notAfter": "2024-03-05T00:00:00Z"}`)
+	assert.NoError(t, err)
+	assert.True(t, rotate)
+
+	rotate, err = shouldRotate(`{"notAfter": 1709294400}`)
+	assert.NoError(t, err)
+	assert.True(t, rotate, "an expiry in Unix seconds should be read")
+
+	for _, value := range []string{`{}`, `{"notAfter": "next tuesday"}`, `{"notAfter": true}`, `not-json`} {
+		_, err = shouldRotate(value)
+		assert.Error(t, err, value)
+		assert.NotContains(t, err.Error(), value, "errors should not include the secret")
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	steps := []Step{StepCreate, StepSet, StepTest, StepFinish}
+	event := Event{SecretId: "my-certificate", ClientRequestToken: "version-1"}
+
+	t.Run("fresh secret is not rotated", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"my-certificate": "v0"})
+		sm.secrets["my-certificate"]["initial"].created = time.Now().Add(-time.Hour)
+		svc := &freshnessService{mockService: mockService{OnCreate: StringSecret("v1")}, FreshnessPolicy: MinAge(24 * time.Hour)}
+		r := testRotator(t, sm, svc).(*rotator)
+		metrics := &recordingMetrics{}
+		r.metrics = metrics
+
+		for _, step := range steps {
+			event.Step = step
+			assert.NoError(t, r.Handle(context.TODO(), event))
+		}
+		assert.Empty(t, svc.CreateCalled)
+		assert.Empty(t, svc.SetCalled)
+		assert.Empty(t, svc.TestCalled)
+		assert.Empty(t, svc.FinishCalled)
+		assert.Equal(t, map[string][]string{"initial": {AWSCURRENT}}, sm.Stages("my-certificate"))
+		assert.Equal(t, []string{"createSecret skipped", "setSecret skipped", "testSecret skipped", "finishSecret skipped"}, metrics.Steps)
+	})
+
+	t.Run("due secret is rotated", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"my-certificate": "v0"})
+		sm.secrets["my-certificate"]["initial"].created = time.Now().Add(-48 * time.Hour)
+		svc := &freshnessService{mockService: mockService{OnCreate: StringSecret("v1")}, FreshnessPolicy: MinAge(24 * time.Hour)}
+		r := testRotator(t, sm, svc)
+
+		for _, step := range steps {
+			event.Step = step
+			assert.NoError(t, r.Handle(context.TODO(), event))
+		}
+		assert.Len(t, svc.CreateCalled, 1)
+		assert.Len(t, svc.FinishCalled, 1)
+		assert.Equal(t, "v1", sm.Current("my-certificate"))
+	})
+
+	t.Run("missing pending version fails unless fresh", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"my-certificate": "v0"})
+		sm.secrets["my-certificate"]["initial"].created = time.Now().Add(-48 * time.Hour)
+		due := &freshnessService{mockService: mockService{}, FreshnessPolicy: MinAge(24 * time.Hour)}
+
+		for _, service := range []Service{&mockService{}, due} {
+			for _, step := range []Step{StepSet, StepTest, StepFinish} {
+				event.Step = step
+				err := testRotator(t, sm, service).Handle(context.TODO(), event)
+				var notFound *types.ResourceNotFoundException
+				assert.ErrorAs(t, err, &notFound, "%T %s", service, step)
+			}
+		}
+		assert.Equal(t, "v0", sm.Current("my-certificate"))
+	})
+
+	t.Run("policy errors fail the step", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"my-certificate": "v0"})
+		svc := &freshnessService{mockService: mockService{OnCreate: StringSecret("v1")}, FreshnessPolicy: ExpiresWithin(time.Hour, ExpiryField("notAfter"))}
+
+		event.Step = StepCreate
+		assert.Error(t, testRotator(t, sm, svc).Handle(context.TODO(), event))
+		assert.Empty(t, svc.CreateCalled)
+	})
+
+	t.Run("groups ignore freshness", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"my-certificate": "v0"})
+		sm.secrets["my-certificate"]["initial"].created = time.Now()
+		never := FreshnessPolicyFunc(func(context.Context, Secret, SecretVersion) (bool, string, error) {
+			return false, "", errors.New("should not be consulted")
+		})
+		svc := &freshnessService{mockService: mockService{OnCreate: StringSecret("v1")}, FreshnessPolicy: never}
+
+		g, err := NewGroup(GroupConfig{Config: Config{SecretsManager: sm, Service: svc}, Members: []GroupMember{{SecretId: "my-certificate"}}})
+		if assert.NoError(t, err) {
+			g.members[0].rotator.logger = testRotator(t, sm, svc).(*rotator).logger
+			assert.NoError(t, g.Rotate(context.TODO()))
+			assert.Equal(t, "v1", sm.Current("my-certificate"))
+		}
+	})
+}
+
+type freshnessService struct {
+	mockService
+	FreshnessPolicy
+}
@@ -0,0 +1,243 @@
+package rotate
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// GroupMember is one of the secrets rotated by a Group
+type GroupMember struct {
+	SecretId string
+
+	// Service rotates this secret, defaults to the Service of the GroupConfig
+	Service Service
+}
+
+type GroupConfig struct {
+	// Config is shared by every member, its Service is used by members without their own
+	Config
+
+	// Members are rotated in order during each step, and rolled back in reverse order
+	Members []GroupMember
+}
+
+// Group rotates a set of secrets in lockstep, for credentials that are only valid together
+// Every member is created before any is set, every member is set before any is tested, and members are only promoted
+// once every Test has passed. When any step fails, the members already applied are rolled back: AWSCURRENT is restored
+// to its previous version, a RollbackService is asked to undo its changes and AWSPENDING is removed.
+//
+// The Group starts rotations itself rather than responding to Secrets Manager, so its members must not also have
+// rotation enabled in Secrets Manager, and a Group must not be rotated concurrently unless Config.Locker is set. As the
+// Group is rotated on demand, a FreshnessService is never consulted.
+type Group struct {
+	members      []groupMember
+	newVersionId func() (string, error)
+}
+
+type groupMember struct {
+	secretId string
+	rotator  *rotator
+	handler  Handler
+}
+
+// NewGroup returns a Group rotating each of c.Members
+func NewGroup(c GroupConfig) (*Group, error) {
+	if len(c.Members) == 0 {
+		return nil, errors.New("a group requires at least one member")
+	}
+
+	g := &Group{newVersionId: newVersionId}
+	seen := make(map[string]bool, len(c.Members))
+	for _, member := range c.Members {
+		if member.SecretId == "" {
+			return nil, errors.New("group member is missing a SecretId")
+		}
+		if seen[member.SecretId] {
+			return nil, fmt.Errorf("secret %s is a member of the group more than once", member.SecretId)
+		}
+		seen[member.SecretId] = true
+
+		memberConfig := c.Config
+		if member.Service != nil {
+			memberConfig.Service = member.Service
+		}
+		if memberConfig.Service == nil {
+			return nil, fmt.Errorf("group member %s has no Service", member.SecretId)
+		}
+
+		r := newRotator(memberConfig)
+		r.ignoreFreshness = true
+		g.members = append(g.members, groupMember{
+			secretId: member.SecretId,
+			rotator:  r,
+			handler:  wrap(r, memberConfig.Middleware),
+		})
+	}
+	return g, nil
+}
+
+// GroupError describes the failure of a Group to rotate
+type GroupError struct {
+	// SecretId and Step identify the member and step that failed
+	SecretId string
+	Step     Step
+	Err      error
+
+	// Rollback is set when a member could not be rolled back, leaving the group partially rotated
+	Rollback error
+}
+
+func (e *GroupError) Error() string {
+	msg := fmt.Sprintf("rotating group: %s of %s failed: %s", e.Step, e.SecretId, e.Err)
+	if e.Rollback != nil {
+		msg += fmt.Sprintf("; rollback failed: %s", e.Rollback)
+	}
+	return msg
+}
+
+func (e *GroupError) Unwrap() []error {
+	if e.Rollback != nil {
+		return []error{e.Err, e.Rollback}
+	}
+	return []error{e.Err}
+}
+
+// groupRotation tracks how far a member has progressed through a rotation of its Group
+type groupRotation struct {
+	groupMember
+	event           Event
+	previousVersion string
+
+	created      bool
+	setAttempted bool
+}
+
+// Rotate rotates every member of the group, returning a *GroupError when the group could not be rotated
+func (g *Group) Rotate(ctx context.Context) error {
+	rotations := make([]*groupRotation, len(g.members))
+	for i, member := range g.members {
+		versionId, err := g.newVersionId()
+		if err != nil {
+			return fmt.Errorf("generating version id for %s: %w", member.secretId, err)
+		}
+		previousVersion, err := member.currentVersion(ctx)
+		if err != nil {
+			return &GroupError{SecretId: member.secretId, Step: StepCreate, Err: err}
+		}
+		rotations[i] = &groupRotation{
+			groupMember:     member,
+			event:           Event{SecretId: member.secretId, ClientRequestToken: versionId},
+			previousVersion: previousVersion,
+		}
+	}
+
+	for _, step := range []Step{StepCreate, StepSet, StepTest, StepFinish} {
+		for _, rotation := range rotations {
+			rotation.event.Step = step
+			if step == StepSet {
+				rotation.setAttempted = true
+			}
+
+			if err := rotation.handler.Handle(ctx, rotation.event); err != nil {
+				return &GroupError{SecretId: rotation.secretId, Step: step, Err: err, Rollback: g.rollback(ctx, rotations, step)}
+			}
+
+			if step == StepCreate {
+				rotation.created = true
+			}
+		}
+	}
+
+	// Secrets Manager removes AWSPENDING once a rotation it started has finished, the group does the same
+	var errs []error
+	for _, rotation := range rotations {
+		if err := rotation.rotator.updateVersionStage(ctx, rotation.event, AWSPENDING, "", rotation.event.ClientRequestToken); err != nil {
+			errs = append(errs, fmt.Errorf("removing %s from %s: %w", AWSPENDING, rotation.secretId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollback undoes each member in reverse order, after the group failed during step
+// The rollback is not cancelled along with ctx, which may well be why the step failed.
+func (g *Group) rollback(ctx context.Context, rotations []*groupRotation, step Step) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for i := len(rotations) - 1; i >= 0; i-- {
+		rotation := rotations[i]
+		rotation.event.Step = step
+		if err := rotation.rollback(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("rolling back %s: %w", rotation.secretId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollback undoes the rotation of a single member, stopping at the first failure so that the pending version remains
+// available to recover the service by hand
+func (g *groupRotation) rollback(ctx context.Context) error {
+	r := g.rotator.withLogPrefixf("[rollback] ")
+	ctx, state := beginStep(ctx)
+	defer state.destroy()
+
+	// a finish step can fail after AWSCURRENT has moved, e.g. waiting for replicas, so the label itself is checked
+	currentVersion, err := r.stagedVersion(ctx, g.secretId, AWSCURRENT)
+	if err != nil {
+		return err
+	}
+	if currentVersion == g.event.ClientRequestToken {
+		r.logger.Printf("Restoring %s of %s to version %s", AWSCURRENT, g.secretId, g.previousVersion)
+		if err := r.updateVersionStage(ctx, g.event, AWSCURRENT, g.previousVersion, g.event.ClientRequestToken); err != nil {
+			return err
+		}
+	}
+
+	if rollbacker, ok := r.service.(RollbackService); ok && g.setAttempted {
+		current, err := r.secretByVersion(ctx, g.secretId, g.previousVersion)
+		if err != nil {
+			return err
+		}
+		pending, err := r.secretByVersion(ctx, g.secretId, g.event.ClientRequestToken)
+		if err != nil {
+			return err
+		}
+
+		r.logger.Printf("Rolling back Service changes for %s", g.secretId)
+		err = r.auditedHook(ctx, g.event, AuditServiceRollback, "Rollback", func(ctx context.Context) error {
+			return rollbacker.Rollback(ctx, current, pending)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if g.created {
+		r.logger.Printf("Removing %s from version %s of %s", AWSPENDING, g.event.ClientRequestToken, g.secretId)
+		if err := r.updateVersionStage(ctx, g.event, AWSPENDING, "", g.event.ClientRequestToken); err != nil {
+			return err
+		}
+	}
+	r.unlock(ctx, g.event)
+	return nil
+}
+
+// currentVersion returns the version of the secret labelled AWSCURRENT
+func (m groupMember) currentVersion(ctx context.Context) (string, error) {
+	ctx, state := beginStep(ctx)
+	defer state.destroy()
+	versionId, _, err := m.rotator.secretByStage(ctx, m.secretId, AWSCURRENT)
+	return versionId, err
+}
+
+// newVersionId returns a random UUID to use as the ClientRequestToken of a new version
+func newVersionId() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
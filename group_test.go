@@ -0,0 +1,389 @@
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	newGroup := func(t *testing.T, sm *fakeSecretsManager, members ...GroupMember) *Group {
+		g, err := NewGroup(GroupConfig{Config: Config{SecretsManager: sm}, Members: members})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		logOutput := &bytes.Buffer{}
+		t.Cleanup(func() {
+			if logOutput.Len() > 0 {
+				t.Log("Log output from group:\n" + logOutput.String())
+			}
+		})
+		versions := 0
+		g.newVersionId = func() (string, error) {
+			versions++
+			return fmt.Sprintf("version-%d", versions), nil
+		}
+		for _, member := range g.members {
+			member.rotator.logger = log.New(logOutput, "", 0)
+		}
+		return g
+	}
+
+	t.Run("rotates in lockstep", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"app-user": "app-v0", "readonly-user": "readonly-v0"})
+		var calls []string
+		app := &groupService{name: "app", value: "app-v1", calls: &calls}
+		readonly := &groupService{name: "readonly", value: "readonly-v1", calls: &calls}
+
+		g := newGroup(t, sm, GroupMember{SecretId: "app-user", Service: app}, GroupMember{SecretId: "readonly-user", Service: readonly})
+		assert.NoError(t, g.Rotate(context.TODO()))
+
+		assert.Equal(t, []string{
+			"app Create", "readonly Create",
+			"app Set", "readonly Set",
+			"app Test", "readonly Test",
+			"app Finish", "readonly Finish",
+		}, calls)
+		assert.Equal(t, map[string][]string{"initial": {"AWSPREVIOUS"}, "version-1": {"AWSCURRENT"}}, sm.Stages("app-user"))
+		assert.Equal(t, map[string][]string{"initial": {"AWSPREVIOUS"}, "version-2": {"AWSCURRENT"}}, sm.Stages("readonly-user"))
+		assert.Equal(t, "readonly-v1", sm.Current("readonly-user"))
+	})
+
+	t.Run("rolls back a failed test", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"app-user": "app-v0", "readonly-user": "readonly-v0"})
+		var calls []string
+		rejected := errors.New("login failed")
+		app := &groupService{name: "app", value: "app-v1", calls: &calls}
+		readonly := &groupService{name: "readonly", value: "readonly-v1", calls: &calls, failOn: StepTest, err: rejected}
+
+		g := newGroup(t, sm, GroupMember{SecretId: "app-user", Service: app}, GroupMember{SecretId: "readonly-user", Service: readonly})
+		err := g.Rotate(context.TODO())
+
+		var groupErr *GroupError
+		if assert.ErrorAs(t, err, &groupErr) {
+			assert.Equal(t, "readonly-user", groupErr.SecretId)
+			assert.Equal(t, StepTest, groupErr.Step)
+			assert.NoError(t, groupErr.Rollback)
+		}
+		assert.ErrorIs(t, err, rejected)
+		assert.Equal(t, []string{
+			"app Create", "readonly Create",
+			"app Set", "readonly Set",
+			"app Test", "readonly Test",
+			"readonly Rollback readonly-v0 -> readonly-v1", "app Rollback app-v0 -> app-v1",
+		}, calls)
+		assert.Equal(t, map[string][]string{"initial": {"AWSCURRENT"}, "version-1": nil}, sm.Stages("app-user"))
+		assert.Equal(t, map[string][]string{"initial": {"AWSCURRENT"}, "version-2": nil}, sm.Stages("readonly-user"))
+	})
+
+	t.Run("rolls back promoted members", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"client-id": "id-v0", "client-secret": "secret-v0"})
+		var calls []string
+		id := &groupService{name: "id", value: "id-v1", calls: &calls}
+		secret := &groupService{name: "secret", value: "secret-v1", calls: &calls, failOn: StepFinish, err: errors.New("revoke failed")}
+
+		g := newGroup(t, sm, GroupMember{SecretId: "client-id", Service: id}, GroupMember{SecretId: "client-secret", Service: secret})
+		assert.Error(t, g.Rotate(context.TODO()))
+
+		assert.Equal(t, "id-v0", sm.Current("client-id"), "the promoted member should be restored")
+		assert.Equal(t, map[string][]string{"initial": {"AWSCURRENT"}, "version-1": {"AWSPREVIOUS"}}, sm.Stages("client-id"))
+		assert.Equal(t, "secret-v0", sm.Current("client-secret"))
+		assert.Contains(t, calls, "id Rollback id-v0 -> id-v1")
+		assert.Contains(t, calls, "secret Rollback secret-v0 -> secret-v1")
+	})
+
+	t.Run("restores a member that failed after it was promoted", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"client-id": "id-v0", "client-secret": "secret-v0"})
+		var calls []string
+		id := &groupService{name: "id", value: "id-v1", calls: &calls}
+		secret := &groupService{name: "secret", value: "secret-v1", calls: &calls}
+
+		g := newGroup(t, sm, GroupMember{SecretId: "client-id", Service: id}, GroupMember{SecretId: "client-secret", Service: secret})
+		unavailable := errors.New("audit log unavailable")
+		for _, member := range g.members {
+			member.rotator.auditSink = auditSinkFunc(func(record AuditRecord) error {
+				// the promotion of client-secret succeeds, but cannot be audited
+				if record.SecretId == "client-secret" && record.VersionStage == AWSCURRENT && record.MoveToVersionId == record.ClientRequestToken {
+					return unavailable
+				}
+				return nil
+			})
+		}
+
+		var groupErr *GroupError
+		if assert.ErrorAs(t, g.Rotate(context.TODO()), &groupErr) {
+			assert.Equal(t, StepFinish, groupErr.Step)
+			assert.ErrorIs(t, groupErr.Err, unavailable)
+			assert.NoError(t, groupErr.Rollback)
+		}
+		assert.Equal(t, "id-v0", sm.Current("client-id"))
+		assert.Equal(t, "secret-v0", sm.Current("client-secret"), "the member that failed after promotion should be restored")
+	})
+
+	t.Run("rolls back after the context is cancelled", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"first": "first-v0"})
+		var calls []string
+		ctx, cancel := context.WithCancel(context.Background())
+		first := &groupService{name: "first", value: "first-v1", calls: &calls, failOn: StepTest, err: context.Canceled, cancel: cancel}
+
+		g := newGroup(t, sm, GroupMember{SecretId: "first", Service: first})
+		var groupErr *GroupError
+		if assert.ErrorAs(t, g.Rotate(ctx), &groupErr) {
+			assert.NoError(t, groupErr.Rollback)
+		}
+		assert.Contains(t, calls, "first Rollback first-v0 -> first-v1")
+		assert.Equal(t, map[string][]string{"initial": {"AWSCURRENT"}, "version-1": nil}, sm.Stages("first"))
+	})
+
+	t.Run("only rolls back members that were applied", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"first": "first-v0", "second": "second-v0"})
+		var calls []string
+		first := &groupService{name: "first", value: "first-v1", calls: &calls, failOn: StepSet, err: errors.New("database unavailable")}
+		second := &groupService{name: "second", value: "second-v1", calls: &calls}
+
+		g := newGroup(t, sm, GroupMember{SecretId: "first", Service: first}, GroupMember{SecretId: "second", Service: second})
+		assert.Error(t, g.Rotate(context.TODO()))
+
+		assert.Equal(t, []string{
+			"first Create", "second Create",
+			"first Set",
+			"first Rollback first-v0 -> first-v1",
+		}, calls)
+		assert.Equal(t, map[string][]string{"initial": {"AWSCURRENT"}, "version-2": nil}, sm.Stages("second"))
+	})
+
+	t.Run("keeps pending when rollback fails", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"first": "first-v0"})
+		var calls []string
+		stuck := errors.New("cannot restore password")
+		first := &groupService{name: "first", value: "first-v1", calls: &calls, failOn: StepTest, err: errors.New("rejected"), rollbackErr: stuck}
+
+		g := newGroup(t, sm, GroupMember{SecretId: "first", Service: first})
+		err := g.Rotate(context.TODO())
+
+		var groupErr *GroupError
+		if assert.ErrorAs(t, err, &groupErr) {
+			assert.ErrorIs(t, groupErr.Rollback, stuck)
+		}
+		assert.ErrorIs(t, err, stuck)
+		assert.Equal(t, map[string][]string{"initial": {"AWSCURRENT"}, "version-1": {"AWSPENDING"}}, sm.Stages("first"))
+	})
+}
+
+func TestNewGroup(t *testing.T) {
+	sm := newFakeSecretsManager(nil)
+	_, err := NewGroup(GroupConfig{Config: Config{SecretsManager: sm, Service: &mockService{}}})
+	assert.Error(t, err, "a group should require members")
+
+	_, err = NewGroup(GroupConfig{Config: Config{SecretsManager: sm}, Members: []GroupMember{{SecretId: "first"}}})
+	assert.Error(t, err, "each member should require a Service")
+
+	_, err = NewGroup(GroupConfig{
+		Config:  Config{SecretsManager: sm, Service: &mockService{}},
+		Members: []GroupMember{{SecretId: "first"}, {SecretId: "first"}},
+	})
+	assert.Error(t, err, "members should be unique")
+}
+
+func TestNewVersionId(t *testing.T) {
+	first, err := newVersionId()
+	assert.NoError(t, err)
+	second, err := newVersionId()
+	assert.NoError(t, err)
+
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, first)
+	assert.NotEqual(t, first, second)
+}
+
+// groupService records each call into it, failing with err during failOn
+type groupService struct {
+	name        string
+	value       string
+	calls       *[]string
+	failOn      Step
+	err         error
+	rollbackErr error
+
+	// cancel is called when failing, as when the step fails because the invocation ran out of time
+	cancel context.CancelFunc
+}
+
+func (g *groupService) call(step Step, name string) error {
+	*g.calls = append(*g.calls, g.name+" "+name)
+	if g.failOn == step {
+		if g.cancel != nil {
+			g.cancel()
+		}
+		return g.err
+	}
+	return nil
+}
+
+func (g *groupService) Create(context.Context, Secret) (Secret, error) {
+	return StringSecret(g.value), g.call(StepCreate, "Create")
+}
+
+func (g *groupService) Set(context.Context, Secret, Secret) error {
+	return g.call(StepSet, "Set")
+}
+
+func (g *groupService) Test(context.Context, Secret) error {
+	return g.call(StepTest, "Test")
+}
+
+func (g *groupService) Finish(context.Context, Secret) error {
+	return g.call(StepFinish, "Finish")
+}
+
+func (g *groupService) Rollback(ctx context.Context, current Secret, pending Secret) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	currentValue, _ := current.Value()
+	pendingValue, _ := pending.Value()
+	*g.calls = append(*g.calls, fmt.Sprintf("%s Rollback %s -> %s", g.name, currentValue, pendingValue))
+	return g.rollbackErr
+}
+
+// auditSinkFunc adapts a function to an AuditSink
+type auditSinkFunc func(record AuditRecord) error
+
+func (f auditSinkFunc) Record(_ context.Context, record AuditRecord) error {
+	return f(record)
+}
+
+// fakeSecretsManager tracks the versions of each secret and the staging labels attached to them
+type fakeSecretsManager struct {
+	// secrets maps each SecretId to the value and stages of each of its versions
+	secrets map[string]map[string]*fakeVersion
+}
+
+type fakeVersion struct {
+	value   string
+	stages  map[string]bool
+	created time.Time
+}
+
+// newFakeSecretsManager returns secrets with a single "initial" version labelled AWSCURRENT
+func newFakeSecretsManager(current map[string]string) *fakeSecretsManager {
+	f := &fakeSecretsManager{secrets: map[string]map[string]*fakeVersion{}}
+	for secretId, value := range current {
+		f.secrets[secretId] = map[string]*fakeVersion{
+			"initial": {value: value, stages: map[string]bool{AWSCURRENT: true}},
+		}
+	}
+	return f
+}
+
+// Stages returns the staging labels of each version of secretId
+func (f *fakeSecretsManager) Stages(secretId string) map[string][]string {
+	stages := map[string][]string{}
+	for versionId, version := range f.secrets[secretId] {
+		var labels []string
+		for stage := range version.stages {
+			labels = append(labels, stage)
+		}
+		sort.Strings(labels)
+		stages[versionId] = labels
+	}
+	return stages
+}
+
+// Current returns the value of the AWSCURRENT version of secretId
+func (f *fakeSecretsManager) Current(secretId string) string {
+	versionId, _ := f.versionByStage(secretId, AWSCURRENT)
+	return f.secrets[secretId][versionId].value
+}
+
+func (f *fakeSecretsManager) versionByStage(secretId, stage string) (string, bool) {
+	for versionId, version := range f.secrets[secretId] {
+		if version.stages[stage] {
+			return versionId, true
+		}
+	}
+	return "", false
+}
+
+func (f *fakeSecretsManager) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	versionId := aws.ToString(params.VersionId)
+	if params.VersionStage != nil {
+		var ok bool
+		if versionId, ok = f.versionByStage(*params.SecretId, *params.VersionStage); !ok {
+			return nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("fake: %s has no version labelled %s", *params.SecretId, *params.VersionStage))}
+		}
+	}
+	version, ok := f.secrets[*params.SecretId][versionId]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("fake: %s has no version %s", *params.SecretId, versionId))}
+	}
+	output := &secretsmanager.GetSecretValueOutput{
+		ARN:          params.SecretId,
+		VersionId:    aws.String(versionId),
+		SecretString: aws.String(version.value),
+	}
+	if !version.created.IsZero() {
+		output.CreatedDate = &version.created
+	}
+	return output, nil
+}
+
+func (f *fakeSecretsManager) PutSecretValue(_ context.Context, params *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	versions, ok := f.secrets[*params.SecretId]
+	if !ok {
+		return nil, fmt.Errorf("fake: no secret %s", *params.SecretId)
+	}
+	if existing, ok := versions[*params.ClientRequestToken]; ok && existing.value != aws.ToString(params.SecretString) {
+		return nil, &types.ResourceExistsException{Message: aws.String("fake: version " + *params.ClientRequestToken + " already exists with a different value")}
+	}
+	version := &fakeVersion{value: aws.ToString(params.SecretString), stages: map[string]bool{}}
+	for _, stage := range params.VersionStages {
+		// a stage can only be attached to a single version
+		for _, other := range versions {
+			delete(other.stages, stage)
+		}
+		version.stages[stage] = true
+	}
+	versions[*params.ClientRequestToken] = version
+	return &secretsmanager.PutSecretValueOutput{VersionId: params.ClientRequestToken}, nil
+}
+
+func (f *fakeSecretsManager) UpdateSecretVersionStage(_ context.Context, params *secretsmanager.UpdateSecretVersionStageInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	versions := f.secrets[*params.SecretId]
+	stage := *params.VersionStage
+	attached, _ := f.versionByStage(*params.SecretId, stage)
+
+	if params.RemoveFromVersionId != nil {
+		if attached != *params.RemoveFromVersionId {
+			return nil, fmt.Errorf("fake: %s is not attached to version %s", stage, *params.RemoveFromVersionId)
+		}
+		delete(versions[attached].stages, stage)
+	}
+	if params.MoveToVersionId != nil {
+		if attached != "" && params.RemoveFromVersionId == nil {
+			return nil, fmt.Errorf("fake: %s is attached to version %s, which must be removed", stage, attached)
+		}
+		version, ok := versions[*params.MoveToVersionId]
+		if !ok {
+			return nil, fmt.Errorf("fake: %s has no version %s", *params.SecretId, *params.MoveToVersionId)
+		}
+		version.stages[stage] = true
+
+		// moving AWSCURRENT labels the version it was removed from as AWSPREVIOUS
+		if stage == AWSCURRENT && params.RemoveFromVersionId != nil {
+			for _, other := range versions {
+				delete(other.stages, "AWSPREVIOUS")
+			}
+			versions[*params.RemoveFromVersionId].stages["AWSPREVIOUS"] = true
+		}
+	}
+	return &secretsmanager.UpdateSecretVersionStageOutput{ARN: params.SecretId}, nil
+}
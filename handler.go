@@ -2,8 +2,10 @@ package rotate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"log"
 	"os"
 	"time"
@@ -17,17 +19,69 @@ type Config struct {
 	SecretsManager SecretsManagerApi
 	Service        Service
 	Timeout        time.Duration
+
+	// Middleware wraps the handling of every step, the first Middleware is the outermost
+	Middleware []Middleware
+
+	// HookMiddleware wraps each call into the Service, the first HookMiddleware is the outermost
+	HookMiddleware []HookMiddleware
+
+	// Metrics optionally records the outcome and latency of each step, Service hook and API call
+	Metrics Metrics
+
+	// Notifier optionally receives a Notification as each step starts and completes
+	Notifier Notifier
+
+	// NotifyMode controls whether notifications are delivered in the background, defaults to NotifyAsync
+	NotifyMode NotifyMode
+
+	// AuditSink optionally records each Secrets Manager mutation and Service hook outcome
+	AuditSink AuditSink
+
+	// AuditActor identifies this rotator in audit records, defaults to the AWS_LAMBDA_FUNCTION_NAME environment variable
+	AuditActor string
+
+	// Replicas optionally verifies that the pending version has reached each replica region during the test step, and
+	// that AWSCURRENT has moved in each replica region during the finish step
+	Replicas *ReplicaConfig
+
+	// Locker optionally leases each secret to a single rotation, rejecting steps for any other ClientRequestToken with
+	// a *LockedError until the rotation holding the lease finishes or the lease expires
+	Locker Locker
+
+	// LockTTL is how long a lease lasts without being renewed by the next step, defaults to 15 minutes
+	LockTTL time.Duration
 }
 
 func New(c Config) Handler {
+	return wrap(newRotator(c), c.Middleware)
+}
+
+// newRotator returns the rotator for c, without any Middleware
+func newRotator(c Config) *rotator {
 	if c.Timeout <= 0 {
 		c.Timeout = time.Second
 	}
+	if c.LockTTL <= 0 {
+		c.LockTTL = 15 * time.Minute
+	}
+	if c.AuditActor == "" {
+		c.AuditActor = os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	}
 	return &rotator{
 		api:            c.SecretsManager,
 		service:        c.Service,
 		logger:         log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile),
 		networkTimeout: c.Timeout,
+		hookMiddleware: c.HookMiddleware,
+		metrics:        c.Metrics,
+		notifier:       c.Notifier,
+		notifyMode:     c.NotifyMode,
+		auditSink:      c.AuditSink,
+		auditActor:     c.AuditActor,
+		replicas:       newReplicas(c.Replicas, c.SecretsManager),
+		locker:         c.Locker,
+		lockTTL:        c.LockTTL,
 	}
 }
 
@@ -43,12 +97,51 @@ type rotator struct {
 	service        Service
 	logger         *log.Logger
 	networkTimeout time.Duration
+	hookMiddleware []HookMiddleware
+	metrics        Metrics
+	notifier       Notifier
+	notifyMode     NotifyMode
+	auditSink      AuditSink
+	auditActor     string
+	replicas       *replicas
+	locker         Locker
+	lockTTL        time.Duration
+
+	// ignoreFreshness rotates every secret, even when its Service is a FreshnessService
+	ignoreFreshness bool
 }
 
 func (r *rotator) Handle(ctx context.Context, event Event) error {
-	defer r.logPrefixf("[%s] ", event.Step)()
+	r = r.withLogPrefixf("[%s] ", event.Step)
+	ctx, state := beginStep(ctx)
+	defer state.destroy()
 	r.logger.Printf("Evaluating rotation for secret: %s and version: %s", event.SecretId, event.ClientRequestToken)
 
+	start := time.Now()
+	err := r.lock(ctx, event)
+	if err == nil {
+		err = r.notify(ctx, Notification{Kind: StepStarted, Event: event})
+	}
+	if err == nil {
+		err = r.handle(ctx, event)
+		err = r.notifyCompleted(ctx, event, state.skipped, err)
+	}
+	if err == nil && event.Step == StepFinish {
+		r.unlock(ctx, event)
+	}
+	if r.metrics != nil {
+		outcome := OutcomeSuccess
+		if err != nil {
+			outcome = OutcomeFailure
+		} else if state.skipped {
+			outcome = OutcomeSkipped
+		}
+		r.metrics.StepCompleted(event, outcome, time.Since(start))
+	}
+	return err
+}
+
+func (r *rotator) handle(ctx context.Context, event Event) error {
 	switch event.Step {
 	case StepCreate:
 		return r.create(ctx, event)
@@ -62,33 +155,147 @@ func (r *rotator) Handle(ctx context.Context, event Event) error {
 	return fmt.Errorf("unknown rotate step: %s", event.Step)
 }
 
-func (r *rotator) logPrefixf(format string, params ...interface{}) func() {
-	current := r.logger.Prefix()
-	r.logger.SetPrefix(current + fmt.Sprintf(format, params...))
-	return func() {
-		r.logger.SetPrefix(current)
-	}
+// withLogPrefixf returns a copy of the rotator that adds a prefix to its log lines, leaving the logger of r untouched
+// for steps handled concurrently
+func (r *rotator) withLogPrefixf(format string, params ...interface{}) *rotator {
+	scoped := *r
+	scoped.logger = log.New(r.logger.Writer(), r.logger.Prefix()+fmt.Sprintf(format, params...), r.logger.Flags())
+	return &scoped
 }
 
 func (r *rotator) create(ctx context.Context, event Event) error {
-	currentVersion, current, err := r.secretByStage(ctx, event.SecretId, AWSCURRENT)
+	currentVersion, current, err := r.getSecret(ctx, event.SecretId, AWSCURRENT)
 	if err != nil {
 		return err
 	}
 
-	if currentVersion == event.ClientRequestToken {
-		r.logger.Println(AWSCURRENT + " is already set to " + event.ClientRequestToken)
+	if currentVersion.VersionId == event.ClientRequestToken {
+		r.skip(ctx, AWSCURRENT+" is already set to "+event.ClientRequestToken)
+		return nil
+	}
+
+	if fresh, err := r.fresh(ctx, current, currentVersion); fresh || err != nil {
+		return err
+	}
+
+	pendingVersion, err := r.stagedVersion(ctx, event.SecretId, AWSPENDING)
+	if err != nil {
+		return err
+	}
+	if pendingVersion == event.ClientRequestToken {
+		r.skip(ctx, AWSPENDING+" is already set to "+event.ClientRequestToken)
 		return nil
 	}
+	if pendingVersion != "" {
+		if err = r.removeStalePending(ctx, event, pendingVersion, currentVersion.VersionId); err != nil {
+			return err
+		}
+	}
 
-	pendingSecret, err := r.service.Create(ctx, current)
+	var pendingSecret Secret
+	err = r.hook(ctx, event, func(ctx context.Context) (err error) {
+		pendingSecret, err = r.service.Create(ctx, current)
+		return err
+	})
+	hold(ctx, pendingSecret)
 	if err != nil {
 		return err
 	}
 
+	if err = r.validateParsed(pendingSecret); err != nil {
+		return fmt.Errorf("invalid created secret: %w", err)
+	}
+
+	if encoder, ok := r.service.(EncodingService); ok {
+		pendingSecret, err = encoder.Encode(pendingSecret)
+		hold(ctx, pendingSecret)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = r.validateRaw(pendingSecret); err != nil {
+		return fmt.Errorf("invalid created secret: %w", err)
+	}
+
 	return r.putPendingSecret(ctx, event, pendingSecret)
 }
 
+// removeStalePending removes AWSPENDING from a version left behind by an earlier rotation that did not finish, giving a
+// CleaningService the chance to clean up after the orphaned version first
+func (r *rotator) removeStalePending(ctx context.Context, event Event, pendingVersion, currentVersion string) error {
+	r.logger.Printf("Found %s attached to orphaned version %s", AWSPENDING, pendingVersion)
+	cleaner, ok := r.service.(CleaningService)
+	if ok && pendingVersion != currentVersion {
+		// the label may also have been left on the version in use, which must not be cleaned up
+		version, pending, err := r.loadSecret(ctx, &secretsmanager.GetSecretValueInput{
+			SecretId:  &event.SecretId,
+			VersionId: &pendingVersion,
+		}, "version "+pendingVersion)
+		switch {
+		case version.VersionId == "":
+			return err
+		case err != nil:
+			r.logger.Printf("Orphaned version %s cannot be cleaned up: %s", pendingVersion, err)
+		default:
+			err = r.auditedHook(ctx, event, AuditServiceCleanup, "Clean", func(ctx context.Context) error {
+				return cleaner.Clean(ctx, pending)
+			})
+			if err != nil {
+				return fmt.Errorf("cleaning up orphaned version %s: %w", pendingVersion, err)
+			}
+		}
+	}
+
+	r.logger.Printf("Removing %s from orphaned version %s", AWSPENDING, pendingVersion)
+	return r.updateVersionStage(ctx, event, AWSPENDING, "", pendingVersion)
+}
+
+// stagedVersion returns the version of the secret that stage is attached to, or an empty string when there is none
+// The stages are read with DescribeSecret when the client implements it, as GetSecretValue fails when no version has
+// the stage.
+func (r *rotator) stagedVersion(ctx context.Context, secretId string, stage string) (string, error) {
+	ctx, cancel := r.network(ctx)
+	defer cancel()
+
+	start := time.Now()
+	if describer, ok := r.api.(SecretDescriber); ok {
+		output, err := describer.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &secretId})
+		r.apiCallCompleted(secretId, "DescribeSecret", err, start)
+		if err != nil {
+			return "", err
+		}
+		for versionId, stages := range output.VersionIdsToStages {
+			for _, versionStage := range stages {
+				if versionStage == stage {
+					return versionId, nil
+				}
+			}
+		}
+		return "", nil
+	}
+
+	output, err := r.api.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     &secretId,
+		VersionStage: &stage,
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		// the stage not being attached to any version is an answer, not a failed call
+		r.apiCallCompleted(secretId, "GetSecretValue", nil, start)
+		return "", nil
+	}
+	r.apiCallCompleted(secretId, "GetSecretValue", err, start)
+	if err != nil {
+		return "", err
+	}
+	hold(ctx, OutputAsSecret(output))
+	if output.VersionId == nil {
+		return "", nil
+	}
+	return *output.VersionId, nil
+}
+
 func (r *rotator) set(ctx context.Context, event Event) error {
 	setter, ok := r.service.(SettingService)
 	if !ok {
@@ -102,41 +309,43 @@ func (r *rotator) set(ctx context.Context, event Event) error {
 	}
 
 	if currentVersion == event.ClientRequestToken {
-		r.logger.Println(AWSCURRENT + " is already set to " + event.ClientRequestToken)
+		r.skip(ctx, AWSCURRENT+" is already set to "+event.ClientRequestToken)
 		return nil
 	}
 
-	pendingVersion, pending, err := r.secretByStage(ctx, event.SecretId, AWSPENDING)
-	if err != nil {
+	pending, ok, err := r.pendingSecret(ctx, event)
+	if !ok || err != nil {
 		return err
 	}
 
-	if pendingVersion != event.ClientRequestToken {
-		r.logger.Println(AWSPENDING + " is not currently set to " + event.ClientRequestToken)
-		return nil
-	}
-
-	return setter.Set(ctx, current, pending)
+	return r.hook(ctx, event, func(ctx context.Context) error {
+		return setter.Set(ctx, current, pending)
+	})
 }
 
 func (r *rotator) test(ctx context.Context, event Event) error {
 	tester, ok := r.service.(TestingService)
-	if !ok {
+	if !ok && r.replicas == nil {
 		r.logger.Println("Service does not want to intercept TEST actions")
 		return nil
 	}
 
-	pendingVersion, pending, err := r.secretByStage(ctx, event.SecretId, AWSPENDING)
-	if err != nil {
+	pending, found, err := r.pendingSecret(ctx, event)
+	if !found || err != nil {
 		return err
 	}
 
-	if pendingVersion != event.ClientRequestToken {
-		r.logger.Println(AWSPENDING + " is not currently set to " + event.ClientRequestToken)
-		return nil
+	if err = r.awaitReplicas(ctx, event, AWSPENDING); err != nil {
+		return err
 	}
 
-	return tester.Test(ctx, pending)
+	if !ok {
+		r.logger.Println("Service does not want to intercept TEST actions")
+		return nil
+	}
+	return r.hook(ctx, event, func(ctx context.Context) error {
+		return tester.Test(ctx, pending)
+	})
 }
 
 func (r *rotator) finish(ctx context.Context, event Event) error {
@@ -146,20 +355,16 @@ func (r *rotator) finish(ctx context.Context, event Event) error {
 	}
 
 	if currentVersion == event.ClientRequestToken {
-		r.logger.Println(AWSCURRENT + " is already set to " + event.ClientRequestToken)
-		return nil
+		r.skip(ctx, AWSCURRENT+" is already set to "+event.ClientRequestToken)
+		// a retried finish must still confirm the replicas caught up
+		return r.awaitReplicas(ctx, event, AWSCURRENT)
 	}
 
-	pendingVersion, pending, err := r.secretByStage(ctx, event.SecretId, AWSPENDING)
-	if err != nil {
+	pending, ok, err := r.pendingSecret(ctx, event)
+	if !ok || err != nil {
 		return err
 	}
 
-	if pendingVersion != event.ClientRequestToken {
-		r.logger.Println(AWSPENDING + " is not currently set to " + event.ClientRequestToken)
-		return nil
-	}
-
 	err = func() error {
 		finisher, ok := r.service.(FinishingService)
 		if !ok {
@@ -167,13 +372,18 @@ func (r *rotator) finish(ctx context.Context, event Event) error {
 			return nil
 		}
 
-		return finisher.Finish(ctx, pending)
+		return r.hook(ctx, event, func(ctx context.Context) error {
+			return finisher.Finish(ctx, pending)
+		})
 	}()
 	if err != nil {
 		return err
 	}
 
-	return r.setCurrentSecret(ctx, event, currentVersion)
+	if err = r.setCurrentSecret(ctx, event, currentVersion); err != nil {
+		return err
+	}
+	return r.awaitReplicas(ctx, event, AWSCURRENT)
 }
 
 const (
@@ -182,68 +392,256 @@ const (
 )
 
 func (r *rotator) secretByStage(ctx context.Context, secretId string, stage string) (string, Secret, error) {
-	ctx, cancel := r.network(ctx)
-	defer cancel()
+	version, secret, err := r.getSecret(ctx, secretId, stage)
+	return version.VersionId, secret, err
+}
 
-	output, err := r.api.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+func (r *rotator) getSecret(ctx context.Context, secretId string, stage string) (SecretVersion, Secret, error) {
+	return r.loadSecret(ctx, &secretsmanager.GetSecretValueInput{
 		SecretId:     &secretId,
 		VersionStage: &stage,
-	})
+	}, stage)
+}
+
+func (r *rotator) secretByVersion(ctx context.Context, secretId string, versionId string) (Secret, error) {
+	_, secret, err := r.loadSecret(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:  &secretId,
+		VersionId: &versionId,
+	}, "version "+versionId)
+	return secret, err
+}
+
+// pendingSecret returns the AWSPENDING secret when it belongs to the rotation of event, otherwise the step is skipped
+func (r *rotator) pendingSecret(ctx context.Context, event Event) (Secret, bool, error) {
+	pendingVersion, pending, err := r.secretByStage(ctx, event.SecretId, AWSPENDING)
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		// no version is pending only when the create step found the current version still fresh
+		if fresh, freshErr := r.currentFresh(ctx, event); fresh || freshErr != nil {
+			return nil, false, freshErr
+		}
+	}
 	if err != nil {
-		return "", BinarySecret{}, err
+		return pending, false, err
 	}
 
-	secret, err := r.prepareSecret(output)
-	return *output.VersionId, secret, err
+	if pendingVersion != event.ClientRequestToken {
+		r.skip(ctx, AWSPENDING+" is not currently set to "+event.ClientRequestToken)
+		return nil, false, nil
+	}
+	return pending, true, nil
 }
 
-func (r *rotator) putPendingSecret(ctx context.Context, event Event, value Secret) error {
+// loadSecret loads, parses and validates the secret selected by input, label describes it in errors
+func (r *rotator) loadSecret(ctx context.Context, input *secretsmanager.GetSecretValueInput, label string) (SecretVersion, Secret, error) {
 	ctx, cancel := r.network(ctx)
 	defer cancel()
 
+	start := time.Now()
+	output, err := r.api.GetSecretValue(ctx, input)
+	r.apiCallCompleted(*input.SecretId, "GetSecretValue", err, start)
+	if err != nil {
+		return SecretVersion{}, BinarySecret{}, err
+	}
+
+	version := SecretVersion{VersionId: *output.VersionId, VersionStages: output.VersionStages}
+	if output.CreatedDate != nil {
+		version.CreatedDate = *output.CreatedDate
+	}
+
+	// the raw document is validated before parsing, which may fill in fields that it is missing
+	secret := OutputAsSecret(output)
+	hold(ctx, secret)
+	if err = r.validateRaw(secret); err != nil {
+		return version, secret, fmt.Errorf("invalid %s secret: %w", label, err)
+	}
+
+	secret, err = r.prepareSecret(ctx, secret)
+	if err != nil {
+		return version, secret, err
+	}
+
+	if err = r.validateParsed(secret); err != nil {
+		return version, secret, fmt.Errorf("invalid %s secret: %w", label, err)
+	}
+	return version, secret, nil
+}
+
+func (r *rotator) putPendingSecret(ctx context.Context, event Event, value Secret) error {
+	apiCtx, cancel := r.network(ctx)
+	defer cancel()
+
 	input := &secretsmanager.PutSecretValueInput{
 		SecretId:           &event.SecretId,
 		ClientRequestToken: &event.ClientRequestToken,
 		VersionStages:      []string{AWSPENDING},
 	}
 
-	val, err := value.Value()
-	if err != nil {
-		return err
-	}
-	if value.Binary() {
-		input.SecretBinary = val
+	// a StringSecret is sent as is, rather than leaving further copies of its value on the heap
+	if str, ok := value.(StringSecret); ok {
+		input.SecretString = (*string)(&str)
 	} else {
-		str := string(val)
-		input.SecretString = &str
+		val, err := value.Value()
+		if err != nil {
+			return err
+		}
+		if value.Binary() {
+			input.SecretBinary = val
+		} else {
+			str := string(val)
+			input.SecretString = &str
+		}
 	}
 
-	_, err = r.api.PutSecretValue(ctx, input)
-	return err
+	start := time.Now()
+	_, err := r.api.PutSecretValue(apiCtx, input)
+	r.apiCallCompleted(event.SecretId, "PutSecretValue", err, start)
+	return r.audit(ctx, AuditRecord{
+		Action:             AuditPutSecretValue,
+		SecretId:           event.SecretId,
+		Step:               event.Step,
+		ClientRequestToken: event.ClientRequestToken,
+		VersionStages:      input.VersionStages,
+	}, err)
 }
 
 func (r *rotator) setCurrentSecret(ctx context.Context, event Event, existingVersion string) error {
-	ctx, cancel := r.network(ctx)
+	return r.updateVersionStage(ctx, event, AWSCURRENT, event.ClientRequestToken, existingVersion)
+}
+
+// updateVersionStage moves stage to moveToVersionId and away from removeFromVersionId, either of which may be empty
+func (r *rotator) updateVersionStage(ctx context.Context, event Event, stage, moveToVersionId, removeFromVersionId string) error {
+	apiCtx, cancel := r.network(ctx)
 	defer cancel()
 
-	stage := AWSCURRENT
-	_, err := r.api.UpdateSecretVersionStage(ctx, &secretsmanager.UpdateSecretVersionStageInput{
-		SecretId:            &event.SecretId,
-		VersionStage:        &stage,
-		MoveToVersionId:     &event.ClientRequestToken,
-		RemoveFromVersionId: &existingVersion,
-	})
-	return err
+	input := &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:     &event.SecretId,
+		VersionStage: &stage,
+	}
+	if moveToVersionId != "" {
+		input.MoveToVersionId = &moveToVersionId
+	}
+	if removeFromVersionId != "" {
+		input.RemoveFromVersionId = &removeFromVersionId
+	}
+
+	start := time.Now()
+	_, err := r.api.UpdateSecretVersionStage(apiCtx, input)
+	r.apiCallCompleted(event.SecretId, "UpdateSecretVersionStage", err, start)
+	return r.audit(ctx, AuditRecord{
+		Action:              AuditUpdateSecretVersionStage,
+		SecretId:            event.SecretId,
+		Step:                event.Step,
+		ClientRequestToken:  event.ClientRequestToken,
+		VersionStage:        stage,
+		MoveToVersionId:     moveToVersionId,
+		RemoveFromVersionId: removeFromVersionId,
+	}, err)
+}
+
+// hook calls into the Service through each HookMiddleware
+func (r *rotator) hook(ctx context.Context, event Event, hook Hook) error {
+	return r.auditedHook(ctx, event, AuditServiceHook, hookName(event.Step), hook)
+}
+
+// auditedHook calls the Service method name through each HookMiddleware, recording the outcome as action
+func (r *rotator) auditedHook(ctx context.Context, event Event, action AuditAction, name string, hook Hook) error {
+	if r.metrics != nil {
+		call := hook
+		hook = func(ctx context.Context) error {
+			start := time.Now()
+			err := call(ctx)
+			r.metrics.HookCompleted(event, err, time.Since(start))
+			return err
+		}
+	}
+	for i := len(r.hookMiddleware) - 1; i >= 0; i-- {
+		hook = r.hookMiddleware[i](event, name, hook)
+	}
+	return r.audit(ctx, AuditRecord{
+		Action:             action,
+		SecretId:           event.SecretId,
+		Step:               event.Step,
+		ClientRequestToken: event.ClientRequestToken,
+	}, hook(ctx))
+}
+
+func (r *rotator) apiCallCompleted(secretId string, operation string, err error, start time.Time) {
+	if r.metrics != nil {
+		r.metrics.APICallCompleted(secretId, operation, err, time.Since(start))
+	}
 }
 
 func (r *rotator) network(ctx context.Context) (context.Context, context.CancelFunc) {
 	return context.WithTimeout(ctx, r.networkTimeout)
 }
 
-func (r *rotator) prepareSecret(secretValue *secretsmanager.GetSecretValueOutput) (secret Secret, err error) {
-	secret = OutputAsSecret(secretValue)
-	if parser, ok := r.service.(ParsingService); ok {
-		secret, err = parser.Parse(secret)
+func (r *rotator) prepareSecret(ctx context.Context, secret Secret) (Secret, error) {
+	parser, ok := r.service.(ParsingService)
+	if !ok {
+		return secret, nil
+	}
+	secret, err := parser.Parse(secret)
+	hold(ctx, secret)
+	return secret, err
+}
+
+// validateParsed validates a secret in the form given to the Service
+func (r *rotator) validateParsed(secret Secret) error {
+	if validating, ok := secret.(ValidatingSecret); ok {
+		return validating.Validate()
+	}
+	return nil
+}
+
+// validateRaw validates a secret in the form stored in Secrets Manager
+func (r *rotator) validateRaw(secret Secret) error {
+	if validator, ok := r.service.(ValidatingService); ok {
+		return validator.Validate(secret)
+	}
+	return nil
+}
+
+type stepStateKey struct{}
+
+// stepState is collected while handling a single step
+type stepState struct {
+	// secrets are loaded, parsed or created during the step and destroyed once it completes
+	secrets []Secret
+
+	// skipped is set when the step had already been completed
+	skipped bool
+}
+
+// beginStep returns a context that collects the state of a step
+func beginStep(ctx context.Context) (context.Context, *stepState) {
+	state := &stepState{}
+	return context.WithValue(ctx, stepStateKey{}, state), state
+}
+
+// destroy destroys each secret held by the step
+func (s *stepState) destroy() {
+	for _, secret := range s.secrets {
+		secret.(DestroyableSecret).Destroy()
+	}
+	s.secrets = nil
+}
+
+// hold records secret to be destroyed once the step completes, when it is a DestroyableSecret
+func hold(ctx context.Context, secret Secret) {
+	state, ok := ctx.Value(stepStateKey{}).(*stepState)
+	if !ok {
+		return
+	}
+	if _, ok = secret.(DestroyableSecret); ok {
+		state.secrets = append(state.secrets, secret)
+	}
+}
+
+// skip logs why the step has nothing left to do and records that it was skipped
+func (r *rotator) skip(ctx context.Context, reason string) {
+	r.logger.Println(reason)
+	if state, ok := ctx.Value(stepStateKey{}).(*stepState); ok {
+		state.skipped = true
 	}
-	return
 }
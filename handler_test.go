@@ -3,13 +3,18 @@ package rotate
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/stretchr/testify/assert"
 	"log"
 	"math/rand"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -39,7 +44,7 @@ func TestRotator(t *testing.T) {
 			}
 			assert.NoError(t, testRotator(t, sm, svc).Handle(context.TODO(), event))
 
-			if !assertApiCounts(t, sm, apiCounts{Lookups: 1, Creates: 1}) || !assertServiceCounts(t, svc, serviceCounts{Creates: 1, Parses: 1}) {
+			if !assertApiCounts(t, sm, apiCounts{Lookups: 2, Creates: 1}) || !assertServiceCounts(t, svc, serviceCounts{Creates: 1, Parses: 1}) {
 				return
 			}
 
@@ -308,6 +313,249 @@ func TestRotator(t *testing.T) {
 			}
 		})
 	})
+	t.Run("validation", func(t *testing.T) {
+		t.Run("invalid current secret fails before create", func(t *testing.T) {
+			event := testEvent(StepCreate)
+
+			currentValue := "invalid-current"
+			sm := &mockSecretsManager{
+				Existing: map[string]*secretsmanager.GetSecretValueOutput{
+					AWSCURRENT: {VersionId: testVersionId(), SecretString: &currentValue},
+				},
+			}
+			fieldErr := errors.New("field password: is required")
+			svc := &validatingService{
+				mockService: &mockService{OnCreate: StringSecret("new")},
+				invalid:     map[string]error{currentValue: fieldErr},
+			}
+
+			err := testRotator(t, sm, svc).Handle(context.TODO(), event)
+			assert.ErrorIs(t, err, fieldErr)
+			assert.Contains(t, err.Error(), AWSCURRENT)
+			assertApiCounts(t, sm, apiCounts{Lookups: 1})
+			assertServiceCounts(t, svc.mockService, serviceCounts{})
+		})
+
+		t.Run("invalid created secret is not stored", func(t *testing.T) {
+			event := testEvent(StepCreate)
+
+			currentValue := strconv.Itoa(rand.Int())
+			sm := &mockSecretsManager{
+				Existing: map[string]*secretsmanager.GetSecretValueOutput{
+					AWSCURRENT: {VersionId: testVersionId(), SecretString: &currentValue},
+				},
+			}
+			fieldErr := errors.New("field port: must be of type integer")
+			svc := &mockService{OnCreate: &invalidSecret{StringSecret: "new", err: fieldErr}}
+
+			err := testRotator(t, sm, svc).Handle(context.TODO(), event)
+			assert.ErrorIs(t, err, fieldErr)
+			assertApiCounts(t, sm, apiCounts{Lookups: 2})
+			assertServiceCounts(t, svc, serviceCounts{Parses: 1, Creates: 1})
+		})
+
+		t.Run("invalid pending secret fails before test", func(t *testing.T) {
+			event := testEvent(StepTest)
+
+			pendingValue := "invalid-pending"
+			sm := &mockSecretsManager{
+				Existing: map[string]*secretsmanager.GetSecretValueOutput{
+					AWSPENDING: {VersionId: &event.ClientRequestToken, SecretString: &pendingValue},
+				},
+			}
+			fieldErr := errors.New("field username: is required")
+			svc := &validatingService{
+				mockService: &mockService{},
+				invalid:     map[string]error{pendingValue: fieldErr},
+			}
+
+			err := testRotator(t, sm, svc).Handle(context.TODO(), event)
+			assert.ErrorIs(t, err, fieldErr)
+			assert.Contains(t, err.Error(), AWSPENDING)
+			assertServiceCounts(t, svc.mockService, serviceCounts{})
+		})
+
+		t.Run("services validate the raw document rather than the parsed secret", func(t *testing.T) {
+			for name, c := range map[string]struct {
+				current string
+				created string
+				label   string
+			}{
+				"current": {current: `{"username": "app"}`, created: `{"username": "app", "password": "new"}`, label: AWSCURRENT},
+				"created": {current: `{"username": "app", "password": "old"}`, created: `{"username": "app"}`, label: "created"},
+			} {
+				t.Run(name, func(t *testing.T) {
+					current := c.current
+					sm := &mockSecretsManager{
+						Existing: map[string]*secretsmanager.GetSecretValueOutput{
+							AWSCURRENT: {VersionId: testVersionId(), SecretString: &current},
+						},
+					}
+
+					err := testRotator(t, sm, documentService{created: StringSecret(c.created)}).Handle(context.TODO(), testEvent(StepCreate))
+					assert.EqualError(t, err, "invalid "+c.label+" secret: field password: is required")
+					assert.Empty(t, sm.Creations)
+				})
+			}
+		})
+	})
+
+	t.Run("destroy", func(t *testing.T) {
+		t.Run("secrets are destroyed once the step completes", func(t *testing.T) {
+			event := testEvent(StepCreate)
+
+			currentValue := []byte(strconv.Itoa(rand.Int()))
+			sm := &mockSecretsManager{
+				Existing: map[string]*secretsmanager.GetSecretValueOutput{
+					AWSCURRENT: {VersionId: testVersionId(), SecretBinary: currentValue},
+				},
+			}
+			pending := &destroyableSecret{BinarySecret: BinarySecret("new-secret")}
+			svc := &mockService{OnCreate: pending}
+			assert.NoError(t, testRotator(t, sm, svc).Handle(context.TODO(), event))
+
+			if !assertApiCounts(t, sm, apiCounts{Lookups: 2, Creates: 1}) {
+				return
+			}
+			// the pending secret was stored without being copied, and every reference has since been cleared
+			assert.Equal(t, 0, pending.destroyedBeforePut)
+			assert.Equal(t, make([]byte, len("new-secret")), sm.Creations[0].SecretBinary)
+			assert.Equal(t, make([]byte, len(currentValue)), currentValue)
+			assert.Positive(t, pending.destroyed)
+		})
+
+		t.Run("secrets are destroyed when the step fails", func(t *testing.T) {
+			event := testEvent(StepCreate)
+
+			currentValue := []byte("invalid-current")
+			sm := &mockSecretsManager{
+				Existing: map[string]*secretsmanager.GetSecretValueOutput{
+					AWSCURRENT: {VersionId: testVersionId(), SecretBinary: currentValue},
+				},
+			}
+			fieldErr := errors.New("field password: is required")
+			svc := &validatingService{
+				mockService: &mockService{OnCreate: StringSecret("new")},
+				invalid:     map[string]error{string(currentValue): fieldErr},
+			}
+
+			assert.ErrorIs(t, testRotator(t, sm, svc).Handle(context.TODO(), event), fieldErr)
+			assert.Equal(t, make([]byte, len(currentValue)), currentValue)
+		})
+	})
+}
+
+func TestConcurrentLogPrefixes(t *testing.T) {
+	output := &lockedBuffer{}
+	r := &rotator{service: plainService{}, logger: log.New(output, "", 0), networkTimeout: time.Second}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, step := range []Step{StepSet, StepTest} {
+			wg.Add(1)
+			go func(step Step) {
+				defer wg.Done()
+				assert.NoError(t, r.Handle(context.TODO(), testEvent(step)))
+			}(step)
+		}
+	}
+	wg.Wait()
+
+	assert.Empty(t, r.logger.Prefix())
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		assert.Equal(t, 1, strings.Count(line, "Secret]"), line)
+	}
+}
+
+// plainService only creates, so the other steps have nothing to do
+type plainService struct{}
+
+func (plainService) Create(context.Context, Secret) (Secret, error) {
+	return StringSecret("new"), nil
+}
+
+func TestStalePending(t *testing.T) {
+	// abandoned returns a secret whose earlier rotation left AWSPENDING attached to the "abandoned" version
+	abandoned := func(t *testing.T) *fakeSecretsManager {
+		sm := newFakeSecretsManager(map[string]string{"my-secret": "v0"})
+		_, err := sm.PutSecretValue(context.TODO(), &secretsmanager.PutSecretValueInput{
+			SecretId:           aws.String("my-secret"),
+			ClientRequestToken: aws.String("abandoned"),
+			SecretString:       aws.String("abandoned-value"),
+			VersionStages:      []string{AWSPENDING},
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return sm
+	}
+	event := Event{SecretId: "my-secret", ClientRequestToken: "version-1", Step: StepCreate}
+
+	t.Run("cleans up the orphaned version", func(t *testing.T) {
+		sm := abandoned(t)
+		svc := &cleaningService{mockService: mockService{OnCreate: StringSecret("v1")}}
+		assert.NoError(t, testRotator(t, sm, svc).Handle(context.TODO(), event))
+
+		assert.Equal(t, []Secret{StringSecret("abandoned-value")}, svc.Cleaned)
+		assert.Equal(t, map[string][]string{"initial": {AWSCURRENT}, "abandoned": nil, "version-1": {AWSPENDING}}, sm.Stages("my-secret"))
+	})
+
+	t.Run("keeps the label when cleanup fails", func(t *testing.T) {
+		sm := abandoned(t)
+		revokeErr := errors.New("revoke failed")
+		svc := &cleaningService{mockService: mockService{OnCreate: StringSecret("v1")}, err: revokeErr}
+		assert.ErrorIs(t, testRotator(t, sm, svc).Handle(context.TODO(), event), revokeErr)
+
+		assert.Empty(t, svc.CreateCalled)
+		assert.Equal(t, map[string][]string{"initial": {AWSCURRENT}, "abandoned": {AWSPENDING}}, sm.Stages("my-secret"))
+	})
+
+	t.Run("removes the label without a CleaningService", func(t *testing.T) {
+		sm := abandoned(t)
+		assert.NoError(t, testRotator(t, sm, &mockService{OnCreate: StringSecret("v1")}).Handle(context.TODO(), event))
+		assert.Equal(t, map[string][]string{"initial": {AWSCURRENT}, "abandoned": nil, "version-1": {AWSPENDING}}, sm.Stages("my-secret"))
+	})
+
+	t.Run("never cleans up the current version", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"my-secret": "v0"})
+		sm.secrets["my-secret"]["initial"].stages[AWSPENDING] = true
+		svc := &cleaningService{mockService: mockService{OnCreate: StringSecret("v1")}}
+		assert.NoError(t, testRotator(t, sm, svc).Handle(context.TODO(), event))
+
+		assert.Empty(t, svc.Cleaned)
+		assert.Equal(t, map[string][]string{"initial": {AWSCURRENT}, "version-1": {AWSPENDING}}, sm.Stages("my-secret"))
+	})
+
+	t.Run("reads the stages with DescribeSecret when the client can", func(t *testing.T) {
+		sm := abandoned(t)
+		svc := &cleaningService{mockService: mockService{OnCreate: StringSecret("v1")}}
+		r := testRotator(t, &describingSecretsManager{fakeSecretsManager: sm}, svc).(*rotator)
+		metrics := &recordingMetrics{}
+		r.metrics = metrics
+		assert.NoError(t, r.Handle(context.TODO(), event))
+
+		assert.Equal(t, []Secret{StringSecret("abandoned-value")}, svc.Cleaned)
+		assert.Equal(t, []string{
+			"GetSecretValue <nil>",
+			"DescribeSecret <nil>",
+			"GetSecretValue <nil>",
+			"UpdateSecretVersionStage <nil>",
+			"PutSecretValue <nil>",
+		}, metrics.APICalls)
+		assert.Equal(t, map[string][]string{"initial": {AWSCURRENT}, "abandoned": nil, "version-1": {AWSPENDING}}, sm.Stages("my-secret"))
+	})
+
+	t.Run("leaves a retried create alone", func(t *testing.T) {
+		sm := newFakeSecretsManager(map[string]string{"my-secret": "v0"})
+		svc := &cleaningService{mockService: mockService{OnCreate: StringSecret("v1")}}
+		r := testRotator(t, sm, svc)
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assert.NoError(t, r.Handle(context.TODO(), event))
+
+		assert.Len(t, svc.CreateCalled, 1, "the version stored by the first attempt should be kept")
+		assert.Empty(t, svc.Cleaned)
+		assert.Equal(t, map[string][]string{"initial": {AWSCURRENT}, "version-1": {AWSPENDING}}, sm.Stages("my-secret"))
+	})
 }
 
 type apiCounts struct {
@@ -366,6 +614,36 @@ func testVersionId() *string {
 	return &versionId
 }
 
+// lockedBuffer is a bytes.Buffer that can be written to concurrently
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+// cleaningService records each orphaned version it is asked to clean up
+type cleaningService struct {
+	mockService
+	Cleaned []Secret
+	err     error
+}
+
+func (c *cleaningService) Clean(_ context.Context, orphaned Secret) error {
+	c.Cleaned = append(c.Cleaned, orphaned)
+	return c.err
+}
+
 type setSecretParams struct {
 	Current Secret
 	Pending Secret
@@ -408,6 +686,89 @@ func (m *mockService) Parse(secret Secret) (Secret, error) {
 	return secret, nil
 }
 
+// validatingService rejects secrets whose raw value is present in invalid
+type validatingService struct {
+	*mockService
+	invalid map[string]error
+}
+
+func (v *validatingService) Validate(secret Secret) error {
+	value, err := secret.Value()
+	if err != nil {
+		return err
+	}
+	return v.invalid[string(value)]
+}
+
+// documentService parses JSON documents into a struct, which encodes a missing password as an empty one, and requires
+// the raw document to have a password
+type documentService struct {
+	created Secret
+}
+
+type document struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (d documentService) Create(context.Context, Secret) (Secret, error) {
+	return d.created, nil
+}
+
+func (d documentService) Parse(secret Secret) (Secret, error) {
+	value, err := secret.Value()
+	if err != nil {
+		return nil, err
+	}
+	var parsed document
+	if err = json.Unmarshal(value, &parsed); err != nil {
+		return nil, err
+	}
+	value, err = json.Marshal(parsed)
+	return StringSecret(value), err
+}
+
+func (d documentService) Validate(secret Secret) error {
+	value, err := secret.Value()
+	if err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err = json.Unmarshal(value, &fields); err != nil {
+		return err
+	}
+	if _, ok := fields["password"]; !ok {
+		return errors.New("field password: is required")
+	}
+	return nil
+}
+
+type invalidSecret struct {
+	StringSecret
+	err error
+}
+
+func (i *invalidSecret) Validate() error {
+	return i.err
+}
+
+// destroyableSecret records when it is destroyed, relative to being stored by PutSecretValue
+type destroyableSecret struct {
+	BinarySecret
+	destroyed          int
+	destroyedBeforePut int
+}
+
+func (d *destroyableSecret) Value() ([]byte, error) {
+	d.destroyedBeforePut = d.destroyed
+	return d.BinarySecret.Value()
+}
+
+func (d *destroyableSecret) Destroy() {
+	d.destroyed++
+	d.BinarySecret.Destroy()
+}
+
 type mockSecretsManager struct {
 	Existing   map[string]*secretsmanager.GetSecretValueOutput
 	Lookups    []*secretsmanager.GetSecretValueInput
@@ -422,7 +783,7 @@ func (m *mockSecretsManager) GetSecretValue(_ context.Context, params *secretsma
 			return output, nil
 		}
 	}
-	return nil, errors.New("mock: no output for stage configured: " + *params.VersionStage)
+	return nil, &types.ResourceNotFoundException{Message: aws.String("mock: no output for stage configured: " + *params.VersionStage)}
 }
 
 func (m *mockSecretsManager) PutSecretValue(_ context.Context, params *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
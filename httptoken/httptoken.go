@@ -0,0 +1,361 @@
+package httptoken
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/jsonsecret"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// Document is a JSON object secret holding an API token alongside any other settings for the integration
+type Document map[string]interface{}
+
+func (d Document) Binary() bool {
+	return false
+}
+
+func (d Document) Value() ([]byte, error) {
+	return json.Marshal(d)
+}
+
+func (d Document) Format(f fmt.State, verb rune) {
+	rotate.Redacted{}.Format(f, verb)
+}
+
+func (d Document) GoString() string {
+	return rotate.Redacted{}.GoString()
+}
+
+func (d Document) LogValue() slog.Value {
+	return rotate.Redacted{}.LogValue()
+}
+
+// Ensure that Document remains Secret compatible
+func _(d Document) rotate.Secret {
+	return d
+}
+
+// Request describes a single HTTP call made while rotating a token
+// Method, URL, header values and Body are text/template strings executed against Data.
+type Request struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+}
+
+// Data is the value request templates are executed against
+type Data struct {
+	// Current is the AWSCURRENT secret, available while creating the new token and revoking the previous one
+	Current Document
+
+	// Pending is the AWSPENDING secret, only available while verifying
+	Pending Document
+
+	// Token is the token the request should authenticate with
+	// This is the current token while creating and revoking, and the pending token while verifying.
+	Token string
+
+	// Previous is the token recorded in PreviousTokenField, only available while revoking
+	Previous string
+}
+
+type Config struct {
+	// Client performs the requests, defaults to http.DefaultClient
+	Client *http.Client
+
+	// Auth is a template for the Authorization header added to every request that does not set its own
+	// e.g. `Bearer {{.Token}}`
+	Auth string
+
+	// Create regenerates the token using the current secret, the response must be a JSON document
+	Create Request
+
+	// TokenPath is a JSONPath expression locating the new token in the Create response, e.g. `$.data.token`
+	TokenPath string
+
+	// TokenField is the field of the secret that holds the token, defaults to "token"
+	TokenField string
+
+	// PreviousTokenField optionally records the replaced token in the pending secret so that Revoke can reference it
+	PreviousTokenField string
+
+	// Verify optionally checks that the pending token is accepted, any 2xx response is considered a success
+	Verify *Request
+
+	// Revoke optionally invalidates the token recorded in PreviousTokenField, which it requires
+	// The previous token is revoked at the start of the next rotation rather than when the pending token is promoted, as
+	// AWSCURRENT would be left holding a revoked token should the promotion fail. A 404 or 410 response is taken to mean
+	// the token has already been revoked.
+	Revoke *Request
+}
+
+// Service returns a rotate.Service that regenerates an API token through HTTP calls described by c
+func Service(c Config) (rotate.Service, error) {
+	if c.Client == nil {
+		c.Client = http.DefaultClient
+	}
+	if c.TokenField == "" {
+		c.TokenField = "token"
+	}
+	if c.TokenPath == "" {
+		return nil, errors.New("httptoken: TokenPath is required")
+	}
+	if _, err := parsePath(c.TokenPath); err != nil {
+		return nil, err
+	}
+	if c.Revoke != nil && c.PreviousTokenField == "" {
+		return nil, errors.New("httptoken: Revoke requires a PreviousTokenField")
+	}
+
+	s := &service{
+		SecretParser:  jsonsecret.Parser(Document{}),
+		client:        c.Client,
+		tokenPath:     c.TokenPath,
+		tokenField:    c.TokenField,
+		previousField: c.PreviousTokenField,
+	}
+
+	var err error
+	if s.create, err = compile("create", c.Auth, &c.Create); err != nil {
+		return nil, err
+	}
+	if s.verify, err = compile("verify", c.Auth, c.Verify); err != nil {
+		return nil, err
+	}
+	if s.revoke, err = compile("revoke", c.Auth, c.Revoke); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type service struct {
+	rotate.SecretParser
+	client        *http.Client
+	tokenPath     string
+	tokenField    string
+	previousField string
+	create        *request
+	verify        *request
+	revoke        *request
+}
+
+func (s *service) Create(ctx context.Context, current rotate.Secret) (rotate.Secret, error) {
+	doc, err := document(current)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.revokePrevious(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	body, err := s.create.do(ctx, s.client, Data{Current: doc, Token: s.token(doc)})
+	if err != nil {
+		return nil, err
+	}
+
+	var response interface{}
+	if err = json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("httptoken: create response is not JSON: %w", err)
+	}
+	value, err := extract(response, s.tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("httptoken: %w", err)
+	}
+	token, ok := value.(string)
+	if !ok || token == "" {
+		return nil, fmt.Errorf("httptoken: %s did not resolve to a token", s.tokenPath)
+	}
+
+	pending := make(Document, len(doc)+1)
+	for k, v := range doc {
+		pending[k] = v
+	}
+	pending[s.tokenField] = token
+	if s.previousField != "" {
+		pending[s.previousField] = s.token(doc)
+	}
+	return pending, nil
+}
+
+func (s *service) Test(ctx context.Context, pending rotate.Secret) error {
+	if s.verify == nil {
+		return nil
+	}
+
+	doc, err := document(pending)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.verify.do(ctx, s.client, Data{Pending: doc, Token: s.token(doc)})
+	return err
+}
+
+// revokePrevious revokes the token replaced by the rotation that produced current, now that current has been promoted
+func (s *service) revokePrevious(ctx context.Context, current Document) error {
+	if s.revoke == nil {
+		return nil
+	}
+	previous, _ := current[s.previousField].(string)
+	if previous == "" || previous == s.token(current) {
+		return nil
+	}
+
+	_, err := s.revoke.do(ctx, s.client, Data{Current: current, Token: s.token(current), Previous: previous})
+	var status *statusError
+	if errors.As(err, &status) && (status.code == http.StatusNotFound || status.code == http.StatusGone) {
+		// a retried create finds the token already revoked
+		return nil
+	}
+	return err
+}
+
+func (s *service) token(doc Document) string {
+	token, _ := doc[s.tokenField].(string)
+	return token
+}
+
+func document(secret rotate.Secret) (Document, error) {
+	doc, ok := secret.(Document)
+	if !ok {
+		return nil, fmt.Errorf("httptoken: unexpected secret type %T", secret)
+	}
+	return doc, nil
+}
+
+var funcs = template.FuncMap{
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"json": func(v interface{}) (string, error) {
+		raw, err := json.Marshal(v)
+		return string(raw), err
+	},
+}
+
+// request is a Request with each of its templates compiled
+type request struct {
+	name    string
+	method  *template.Template
+	url     *template.Template
+	headers map[string]*template.Template
+	body    *template.Template
+}
+
+func compile(name string, auth string, r *Request) (*request, error) {
+	if r == nil {
+		return nil, nil
+	}
+	if r.URL == "" {
+		return nil, fmt.Errorf("httptoken: %s request requires a URL", name)
+	}
+
+	method := r.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	headers := make(map[string]string, len(r.Headers)+1)
+	if auth != "" {
+		headers["Authorization"] = auth
+	}
+	for k, v := range r.Headers {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+
+	c := &request{name: name, headers: make(map[string]*template.Template, len(headers))}
+
+	var err error
+	parse := func(field, text string) *template.Template {
+		if err != nil {
+			return nil
+		}
+		var t *template.Template
+		t, err = template.New(name + " " + field).Funcs(funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			err = fmt.Errorf("httptoken: %w", err)
+		}
+		return t
+	}
+
+	c.method = parse("method", method)
+	c.url = parse("url", r.URL)
+	c.body = parse("body", r.Body)
+	for k, v := range headers {
+		c.headers[k] = parse("header "+k, v)
+	}
+	return c, err
+}
+
+// do executes the request templates with data and returns the body of a successful response
+func (r *request) do(ctx context.Context, client *http.Client, data Data) ([]byte, error) {
+	method, err := execute(r.method, data)
+	if err != nil {
+		return nil, err
+	}
+	url, err := execute(r.url, data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := execute(r.body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), url, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("httptoken: %s request: %w", r.name, err)
+	}
+	for k, t := range r.headers {
+		value, err := execute(t, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("httptoken: %s request: %w", r.name, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("httptoken: %s response: %w", r.name, err)
+	}
+	// the response body is deliberately left out of the error as it may contain credentials
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &statusError{name: r.name, status: resp.Status, code: resp.StatusCode}
+	}
+	return respBody, nil
+}
+
+// statusError reports a request that did not receive a 2xx response
+type statusError struct {
+	name   string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("httptoken: %s request returned %s", e.name, e.status)
+}
+
+func execute(t *template.Template, data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("httptoken: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,225 @@
+package httptoken_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/httptoken"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestService(t *testing.T) {
+	t.Run("rotates a token through create, verify and revoke", func(t *testing.T) {
+		api := newTokenApi("old-token")
+		server := httptest.NewServer(api)
+		defer server.Close()
+
+		svc, err := httptoken.Service(httptoken.Config{
+			Client: server.Client(),
+			Auth:   "Bearer {{.Token}}",
+			Create: httptoken.Request{
+				URL:     server.URL + "/accounts/{{.Current.account}}/tokens",
+				Headers: map[string]string{"content-type": "application/json"},
+				Body:    `{"name": {{json .Current.account}}}`,
+			},
+			TokenPath:          "$.data.token",
+			PreviousTokenField: "previous_token",
+			Verify:             &httptoken.Request{Method: "GET", URL: server.URL + "/me"},
+			Revoke:             &httptoken.Request{Method: "DELETE", URL: server.URL + "/tokens/{{.Previous}}"},
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		current, err := svc.(rotate.ParsingService).Parse(rotate.StringSecret(`{"account": "acme", "token": "old-token", "region": "us"}`))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		pending, err := svc.Create(context.TODO(), current)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, httptoken.Document{
+			"account":        "acme",
+			"region":         "us",
+			"token":          "new-token-1",
+			"previous_token": "old-token",
+		}, pending)
+		assert.Equal(t, "Bearer old-token", api.requests[0].Header.Get("Authorization"))
+		assert.Equal(t, "application/json", api.requests[0].Header.Get("Content-Type"))
+		assert.Equal(t, `{"name": "acme"}`, api.bodies[0])
+
+		assert.NoError(t, svc.(rotate.TestingService).Test(context.TODO(), pending))
+		assert.Equal(t, "Bearer new-token-1", api.requests[1].Header.Get("Authorization"))
+
+		_, ok := svc.(rotate.FinishingService)
+		assert.False(t, ok, "the old token must stay valid until the pending token has been promoted")
+
+		// the next rotation revokes the token replaced by the last one
+		next, err := svc.Create(context.TODO(), pending)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/tokens/old-token", api.requests[2].URL.Path)
+		assert.Equal(t, "Bearer new-token-1", api.requests[2].Header.Get("Authorization"))
+		assert.Equal(t, "new-token-1", next.(httptoken.Document)["previous_token"])
+		assert.ElementsMatch(t, []string{"new-token-1", "new-token-2"}, api.validTokens())
+
+		// a retried create finds the previous token already revoked
+		_, err = svc.Create(context.TODO(), pending)
+		assert.NoError(t, err)
+	})
+
+	t.Run("create fails when the api rejects the request", func(t *testing.T) {
+		server := httptest.NewServer(newTokenApi("old-token"))
+		defer server.Close()
+
+		svc, err := httptoken.Service(httptoken.Config{
+			Client:    server.Client(),
+			Auth:      "Bearer {{.Token}}",
+			Create:    httptoken.Request{URL: server.URL + "/accounts/acme/tokens"},
+			TokenPath: "$.data.token",
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = svc.Create(context.TODO(), httptoken.Document{"token": "wrong-token"})
+		assert.Error(t, err)
+	})
+
+	t.Run("create fails when the token cannot be found in the response", func(t *testing.T) {
+		server := httptest.NewServer(newTokenApi("old-token"))
+		defer server.Close()
+
+		svc, err := httptoken.Service(httptoken.Config{
+			Client:    server.Client(),
+			Auth:      "Bearer {{.Token}}",
+			Create:    httptoken.Request{URL: server.URL + "/accounts/acme/tokens"},
+			TokenPath: "$.data.secret",
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = svc.Create(context.TODO(), httptoken.Document{"token": "old-token"})
+		assert.Error(t, err)
+	})
+
+	t.Run("test fails when the pending token is rejected", func(t *testing.T) {
+		server := httptest.NewServer(newTokenApi("old-token"))
+		defer server.Close()
+
+		svc, err := httptoken.Service(httptoken.Config{
+			Client:    server.Client(),
+			Auth:      "Bearer {{.Token}}",
+			Create:    httptoken.Request{URL: server.URL + "/accounts/acme/tokens"},
+			TokenPath: "$.data.token",
+			Verify:    &httptoken.Request{Method: "GET", URL: server.URL + "/me"},
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = svc.(rotate.TestingService).Test(context.TODO(), httptoken.Document{"token": "never-issued"})
+		assert.Error(t, err)
+	})
+
+	t.Run("missing template fields fail rather than sending empty values", func(t *testing.T) {
+		server := httptest.NewServer(newTokenApi("old-token"))
+		defer server.Close()
+
+		svc, err := httptoken.Service(httptoken.Config{
+			Client:    server.Client(),
+			Create:    httptoken.Request{URL: server.URL + "/accounts/{{.Current.account}}/tokens"},
+			TokenPath: "$.data.token",
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = svc.Create(context.TODO(), httptoken.Document{"token": "old-token"})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid configuration is rejected", func(t *testing.T) {
+		cases := map[string]httptoken.Config{
+			"missing token path": {Create: httptoken.Request{URL: "http://localhost"}},
+			"invalid token path": {Create: httptoken.Request{URL: "http://localhost"}, TokenPath: "data.token"},
+			"missing create url": {TokenPath: "$.token"},
+			"invalid template":   {Create: httptoken.Request{URL: "http://localhost/{{.Current"}, TokenPath: "$.token"},
+			"revoke without previous token field": {
+				Create:    httptoken.Request{URL: "http://localhost"},
+				TokenPath: "$.token",
+				Revoke:    &httptoken.Request{URL: "http://localhost"},
+			},
+		}
+
+		for name, c := range cases {
+			t.Run(name, func(t *testing.T) {
+				_, err := httptoken.Service(c)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+// tokenApi is a minimal SaaS API that issues, checks and revokes bearer tokens
+type tokenApi struct {
+	issued   int
+	tokens   map[string]bool
+	requests []*http.Request
+	bodies   []string
+}
+
+func newTokenApi(initial string) *tokenApi {
+	return &tokenApi{tokens: map[string]bool{initial: true}}
+}
+
+func (a *tokenApi) validTokens() []string {
+	var valid []string
+	for token, ok := range a.tokens {
+		if ok {
+			valid = append(valid, token)
+		}
+	}
+	return valid
+}
+
+func (a *tokenApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	a.requests = append(a.requests, r)
+	a.bodies = append(a.bodies, string(body))
+
+	if !a.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/tokens"):
+		a.issued++
+		token := "new-token-" + strconv.Itoa(a.issued)
+		a.tokens[token] = true
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"token": token}})
+	case r.Method == http.MethodGet && r.URL.Path == "/me":
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/tokens/"):
+		token := strings.TrimPrefix(r.URL.Path, "/tokens/")
+		if !a.tokens[token] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(a.tokens, token)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,97 @@
+package httptoken
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// extract resolves a JSONPath expression against a decoded JSON document
+// Only the child and index operators are supported, e.g. `$.data.tokens[0].value` or `$['data']['token']`.
+func extract(document interface{}, path string) (interface{}, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := document
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			if segment.index {
+				return nil, fmt.Errorf("jsonpath %s: cannot index object with [%d]", path, segment.position)
+			}
+			value, ok := node[segment.key]
+			if !ok {
+				return nil, fmt.Errorf("jsonpath %s: no field %q", path, segment.key)
+			}
+			current = value
+		case []interface{}:
+			if !segment.index {
+				return nil, fmt.Errorf("jsonpath %s: cannot select field %q from array", path, segment.key)
+			}
+			position := segment.position
+			if position < 0 {
+				position += len(node)
+			}
+			if position < 0 || position >= len(node) {
+				return nil, fmt.Errorf("jsonpath %s: index %d out of range", path, segment.position)
+			}
+			current = node[position]
+		default:
+			return nil, fmt.Errorf("jsonpath %s: cannot descend into %T", path, current)
+		}
+	}
+	return current, nil
+}
+
+type pathSegment struct {
+	key      string
+	index    bool
+	position int
+}
+
+func parsePath(path string) ([]pathSegment, error) {
+	rest := strings.TrimSpace(path)
+	if !strings.HasPrefix(rest, "$") {
+		return nil, fmt.Errorf("jsonpath %s: must start with $", path)
+	}
+	rest = rest[1:]
+
+	var segments []pathSegment
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("jsonpath %s: empty field name", path)
+			}
+			segments = append(segments, pathSegment{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %s: unterminated [", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			position, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %s: unsupported selector [%s]", path, inner)
+			}
+			segments = append(segments, pathSegment{index: true, position: position})
+		default:
+			return nil, fmt.Errorf("jsonpath %s: unexpected %q", path, rest[0])
+		}
+	}
+	return segments, nil
+}
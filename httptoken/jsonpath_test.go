@@ -0,0 +1,54 @@
+package httptoken
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	var document interface{}
+	err := json.Unmarshal([]byte(`{"data": {"token": "abc", "tokens": [{"value": "first"}, {"value": "last"}]}, "odd key": 1}`), &document)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("resolves supported expressions", func(t *testing.T) {
+		cases := []struct {
+			path     string
+			expected interface{}
+		}{
+			{`$.data.token`, "abc"},
+			{`$['data']['token']`, "abc"},
+			{`$["odd key"]`, float64(1)},
+			{`$.data.tokens[0].value`, "first"},
+			{`$.data.tokens[-1].value`, "last"},
+		}
+
+		for _, c := range cases {
+			t.Run(c.path, func(t *testing.T) {
+				value, err := extract(document, c.path)
+				assert.NoError(t, err)
+				assert.Equal(t, c.expected, value)
+			})
+		}
+	})
+
+	t.Run("fails for paths that do not resolve", func(t *testing.T) {
+		for _, path := range []string{`$.missing`, `$.data[0]`, `$.data.tokens.value`, `$.data.tokens[5]`, `$.data.token.value`} {
+			t.Run(path, func(t *testing.T) {
+				_, err := extract(document, path)
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("fails for malformed expressions", func(t *testing.T) {
+		for _, path := range []string{`data.token`, `$.`, `$[0`, `$[*]`, `$..token`} {
+			t.Run(path, func(t *testing.T) {
+				_, err := parsePath(path)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package iamkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/jsonsecret"
+	"strings"
+	"time"
+)
+
+// IAMApi is the subset of the IAM client used to manage a user's access keys
+type IAMApi interface {
+	ListAccessKeys(ctx context.Context, params *iam.ListAccessKeysInput, optFns ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error)
+	CreateAccessKey(ctx context.Context, params *iam.CreateAccessKeyInput, optFns ...func(*iam.Options)) (*iam.CreateAccessKeyOutput, error)
+	UpdateAccessKey(ctx context.Context, params *iam.UpdateAccessKeyInput, optFns ...func(*iam.Options)) (*iam.UpdateAccessKeyOutput, error)
+	DeleteAccessKey(ctx context.Context, params *iam.DeleteAccessKeyInput, optFns ...func(*iam.Options)) (*iam.DeleteAccessKeyOutput, error)
+}
+
+// STSApi is the subset of the STS client used to verify an access key
+type STSApi interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// Credentials is the JSON secret holding an IAM user's access key pair, other fields of the secret are retained
+type Credentials struct {
+	rotate.Redacted
+	jsonsecret.Unknown
+	UserName        string `json:"UserName"`
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+}
+
+type Config struct {
+	// IAM manages the access keys of the user named in the secret
+	IAM IAMApi
+
+	// STS builds a client authenticated with the provided credentials, used to verify the pending access key
+	STS func(aws.Credentials) STSApi
+
+	// VerifyTimeout is how long the pending access key is given to become usable, defaults to 30 seconds
+	// Newly created access keys are eventually consistent and can be rejected for a short time after creation.
+	VerifyTimeout time.Duration
+
+	// VerifyInterval is the delay between attempts to verify the pending access key, defaults to 2 seconds
+	VerifyInterval time.Duration
+}
+
+// Service returns a rotate.Service that rotates the access key pair of an IAM user
+func Service(c Config) rotate.Service {
+	if c.VerifyTimeout <= 0 {
+		c.VerifyTimeout = 30 * time.Second
+	}
+	if c.VerifyInterval <= 0 {
+		c.VerifyInterval = 2 * time.Second
+	}
+	return rotate.Typed[Credentials](jsonsecret.For[Credentials](), &service{
+		iam:            c.IAM,
+		sts:            c.STS,
+		verifyTimeout:  c.VerifyTimeout,
+		verifyInterval: c.VerifyInterval,
+	})
+}
+
+type service struct {
+	iam            IAMApi
+	sts            func(aws.Credentials) STSApi
+	verifyTimeout  time.Duration
+	verifyInterval time.Duration
+}
+
+func (s *service) Create(ctx context.Context, creds Credentials) (Credentials, error) {
+	if creds.UserName == "" {
+		return Credentials{}, errors.New("iamkey: current secret does not contain a UserName")
+	}
+
+	keys, err := s.accessKeys(ctx, creds.UserName)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	// IAM users are limited to two access keys, so an inactive key has to make room for the new one
+	if len(keys) >= 2 {
+		inactive, ok := inactiveKey(keys, creds.AccessKeyId)
+		if !ok {
+			return Credentials{}, fmt.Errorf("iamkey: user %s already has %d active access keys", creds.UserName, len(keys))
+		}
+		if err = s.deleteAccessKey(ctx, creds.UserName, inactive); err != nil {
+			return Credentials{}, err
+		}
+	}
+
+	output, err := s.iam.CreateAccessKey(ctx, &iam.CreateAccessKeyInput{UserName: &creds.UserName})
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	return Credentials{
+		Unknown:         creds.Unknown,
+		UserName:        creds.UserName,
+		AccessKeyId:     aws.ToString(output.AccessKey.AccessKeyId),
+		SecretAccessKey: aws.ToString(output.AccessKey.SecretAccessKey),
+	}, nil
+}
+
+// Test verifies that the pending access key authenticates as the expected user
+func (s *service) Test(ctx context.Context, creds Credentials) error {
+	client := s.sts(aws.Credentials{
+		AccessKeyID:     creds.AccessKeyId,
+		SecretAccessKey: creds.SecretAccessKey,
+		Source:          "iamkey",
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, s.verifyTimeout)
+	defer cancel()
+
+	for {
+		output, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err == nil {
+			arn := aws.ToString(output.Arn)
+			if !strings.HasSuffix(arn, "/"+creds.UserName) {
+				return fmt.Errorf("iamkey: pending access key authenticated as %s, expected user %s", arn, creds.UserName)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("iamkey: verifying pending access key: %w", err)
+		case <-time.After(s.verifyInterval):
+		}
+	}
+}
+
+// Finish deactivates every access key of the user other than the pending one
+// The deactivated key is still held by AWSCURRENT until the pending version is promoted, so it is only deleted by the
+// Create of the next rotation, leaving it available to be reactivated should the promotion fail.
+func (s *service) Finish(ctx context.Context, creds Credentials) error {
+	keys, err := s.accessKeys(ctx, creds.UserName)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		id := aws.ToString(key.AccessKeyId)
+		if id == creds.AccessKeyId || key.Status == types.StatusTypeInactive {
+			continue
+		}
+
+		_, err = s.iam.UpdateAccessKey(ctx, &iam.UpdateAccessKeyInput{
+			UserName:    &creds.UserName,
+			AccessKeyId: &id,
+			Status:      types.StatusTypeInactive,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clean deletes the access key of a version abandoned by an earlier rotation, which no consumer was switched to
+func (s *service) Clean(ctx context.Context, orphaned Credentials) error {
+	if orphaned.UserName == "" || orphaned.AccessKeyId == "" {
+		return nil
+	}
+	err := s.deleteAccessKey(ctx, orphaned.UserName, orphaned.AccessKeyId)
+	var noSuchEntity *types.NoSuchEntityException
+	if errors.As(err, &noSuchEntity) {
+		return nil
+	}
+	return err
+}
+
+func (s *service) accessKeys(ctx context.Context, userName string) ([]types.AccessKeyMetadata, error) {
+	output, err := s.iam.ListAccessKeys(ctx, &iam.ListAccessKeysInput{UserName: &userName})
+	if err != nil {
+		return nil, err
+	}
+	return output.AccessKeyMetadata, nil
+}
+
+func (s *service) deleteAccessKey(ctx context.Context, userName string, accessKeyId string) error {
+	_, err := s.iam.DeleteAccessKey(ctx, &iam.DeleteAccessKeyInput{
+		UserName:    &userName,
+		AccessKeyId: &accessKeyId,
+	})
+	return err
+}
+
+func inactiveKey(keys []types.AccessKeyMetadata, currentId string) (string, bool) {
+	for _, key := range keys {
+		id := aws.ToString(key.AccessKeyId)
+		if id != currentId && key.Status == types.StatusTypeInactive {
+			return id, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,279 @@
+package iamkey_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/iamkey"
+	"github.com/printerlogic/go-secretsmanager-rotate/jsonsecret"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestService(t *testing.T) {
+	t.Run("parses the current secret into credentials", func(t *testing.T) {
+		svc := iamkey.Service(iamkey.Config{})
+		secret, err := svc.(rotate.ParsingService).Parse(rotate.StringSecret(`{"UserName": "app", "AccessKeyId": "AKIA1", "SecretAccessKey": "s1"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA1", SecretAccessKey: "s1"}, decode(t, secret))
+	})
+
+	t.Run("create makes a new access key for the user", func(t *testing.T) {
+		api := newLocalIAM("app", key("AKIA1", types.StatusTypeActive))
+		svc := iamkey.Service(iamkey.Config{IAM: api})
+
+		pending, err := svc.Create(context.TODO(), parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA1"}))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		creds := decode(t, pending)
+		assert.Equal(t, "app", creds.UserName)
+		assert.Equal(t, "AKIA2", creds.AccessKeyId)
+		assert.Equal(t, "secret-AKIA2", creds.SecretAccessKey)
+		assert.Len(t, api.keys, 2)
+		assert.Empty(t, api.deleted)
+	})
+
+	t.Run("create deletes the inactive key when the user has two keys", func(t *testing.T) {
+		api := newLocalIAM("app", key("AKIA1", types.StatusTypeActive), key("AKIA0", types.StatusTypeInactive))
+		svc := iamkey.Service(iamkey.Config{IAM: api})
+
+		pending, err := svc.Create(context.TODO(), parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA1"}))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []string{"AKIA0"}, api.deleted)
+		assert.Equal(t, "AKIA3", decode(t, pending).AccessKeyId)
+	})
+
+	t.Run("create refuses to delete active keys", func(t *testing.T) {
+		api := newLocalIAM("app", key("AKIA1", types.StatusTypeActive), key("AKIA0", types.StatusTypeActive))
+		svc := iamkey.Service(iamkey.Config{IAM: api})
+
+		_, err := svc.Create(context.TODO(), parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA1"}))
+		assert.Error(t, err)
+		assert.Empty(t, api.deleted)
+		assert.Len(t, api.keys, 2)
+	})
+
+	t.Run("clean deletes the key of an orphaned version", func(t *testing.T) {
+		api := newLocalIAM("app", key("AKIA1", types.StatusTypeActive), key("AKIA2", types.StatusTypeActive))
+		svc := iamkey.Service(iamkey.Config{IAM: api})
+
+		orphaned := parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA2"})
+		assert.NoError(t, svc.(rotate.CleaningService).Clean(context.TODO(), orphaned))
+		assert.Equal(t, []string{"AKIA2"}, api.deleted)
+		assert.NoError(t, svc.(rotate.CleaningService).Clean(context.TODO(), orphaned), "a key that is already gone is clean")
+
+		pending, err := svc.Create(context.TODO(), parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA1"}))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "AKIA3", decode(t, pending).AccessKeyId)
+		}
+	})
+
+	t.Run("create keeps other fields of the secret", func(t *testing.T) {
+		svc := iamkey.Service(iamkey.Config{IAM: newLocalIAM("app", key("AKIA1", types.StatusTypeActive))})
+		current, err := svc.(rotate.ParsingService).Parse(rotate.StringSecret(`{"UserName": "app", "AccessKeyId": "AKIA1", "SecretAccessKey": "s1", "Region": "us-west-2"}`))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		pending, err := svc.Create(context.TODO(), current)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		raw, err := pending.Value()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"UserName": "app", "AccessKeyId": "AKIA2", "SecretAccessKey": "secret-AKIA2", "Region": "us-west-2"}`, string(raw))
+	})
+
+	t.Run("create requires a user name", func(t *testing.T) {
+		svc := iamkey.Service(iamkey.Config{IAM: newLocalIAM("app")})
+		_, err := svc.Create(context.TODO(), parse(t, svc, iamkey.Credentials{AccessKeyId: "AKIA1"}))
+		assert.Error(t, err)
+	})
+
+	t.Run("test verifies the pending key with sts", func(t *testing.T) {
+		local := &localSTS{arns: map[string]string{"AKIA2": "arn:aws:iam::123456789012:user/apps/app"}, failures: 2}
+		svc := iamkey.Service(iamkey.Config{STS: local.client, VerifyInterval: time.Millisecond})
+
+		err := svc.(rotate.TestingService).Test(context.TODO(), parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA2", SecretAccessKey: "secret-AKIA2"}))
+		assert.NoError(t, err)
+		// the key is retried until it propagates
+		assert.Equal(t, 3, local.calls)
+		assert.Equal(t, "secret-AKIA2", local.used.SecretAccessKey)
+	})
+
+	t.Run("test fails when the key authenticates as another user", func(t *testing.T) {
+		local := &localSTS{arns: map[string]string{"AKIA2": "arn:aws:iam::123456789012:user/other"}}
+		svc := iamkey.Service(iamkey.Config{STS: local.client})
+
+		err := svc.(rotate.TestingService).Test(context.TODO(), parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA2"}))
+		assert.Error(t, err)
+	})
+
+	t.Run("test gives up after the verify timeout", func(t *testing.T) {
+		local := &localSTS{arns: map[string]string{}}
+		svc := iamkey.Service(iamkey.Config{STS: local.client, VerifyTimeout: 20 * time.Millisecond, VerifyInterval: time.Millisecond})
+
+		err := svc.(rotate.TestingService).Test(context.TODO(), parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA2"}))
+		assert.Error(t, err)
+		assert.Greater(t, local.calls, 1)
+	})
+
+	t.Run("finish deactivates the old key and leaves it for the next rotation to delete", func(t *testing.T) {
+		api := newLocalIAM("app", key("AKIA1", types.StatusTypeActive), key("AKIA2", types.StatusTypeActive))
+		svc := iamkey.Service(iamkey.Config{IAM: api})
+
+		err := svc.(rotate.FinishingService).Finish(context.TODO(), parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA2"}))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []string{"AKIA1"}, api.deactivated)
+		assert.Empty(t, api.deleted)
+		assert.Len(t, api.keys, 2)
+
+		pending, err := svc.Create(context.TODO(), parse(t, svc, iamkey.Credentials{UserName: "app", AccessKeyId: "AKIA2"}))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"AKIA1"}, api.deleted)
+		assert.Equal(t, "AKIA3", decode(t, pending).AccessKeyId)
+	})
+}
+
+// parse hands creds to svc the way the rotator does, as a parsed secret
+func parse(t *testing.T, svc rotate.Service, creds iamkey.Credentials) rotate.Secret {
+	t.Helper()
+	raw, err := jsonsecret.Marshal(creds)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	secret, err := svc.(rotate.ParsingService).Parse(rotate.StringSecret(raw))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return secret
+}
+
+func decode(t *testing.T, secret rotate.Secret) iamkey.Credentials {
+	t.Helper()
+	creds, err := jsonsecret.For[iamkey.Credentials]().Decode(secret)
+	assert.NoError(t, err)
+	return creds
+}
+
+func key(id string, status types.StatusType) types.AccessKeyMetadata {
+	return types.AccessKeyMetadata{AccessKeyId: aws.String(id), Status: status}
+}
+
+// localIAM is a stand-in for the IAM access key APIs of a single user
+type localIAM struct {
+	userName    string
+	keys        []types.AccessKeyMetadata
+	created     int
+	deactivated []string
+	deleted     []string
+}
+
+func newLocalIAM(userName string, keys ...types.AccessKeyMetadata) *localIAM {
+	return &localIAM{userName: userName, keys: keys, created: len(keys)}
+}
+
+func (l *localIAM) checkUser(userName *string) error {
+	if aws.ToString(userName) != l.userName {
+		return errors.New("local: no such user " + aws.ToString(userName))
+	}
+	return nil
+}
+
+func (l *localIAM) ListAccessKeys(_ context.Context, params *iam.ListAccessKeysInput, _ ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error) {
+	if err := l.checkUser(params.UserName); err != nil {
+		return nil, err
+	}
+	return &iam.ListAccessKeysOutput{AccessKeyMetadata: append([]types.AccessKeyMetadata(nil), l.keys...)}, nil
+}
+
+func (l *localIAM) CreateAccessKey(_ context.Context, params *iam.CreateAccessKeyInput, _ ...func(*iam.Options)) (*iam.CreateAccessKeyOutput, error) {
+	if err := l.checkUser(params.UserName); err != nil {
+		return nil, err
+	}
+	if len(l.keys) >= 2 {
+		return nil, errors.New("local: access key limit exceeded")
+	}
+
+	l.created++
+	id := fmt.Sprintf("AKIA%d", l.created)
+	l.keys = append(l.keys, key(id, types.StatusTypeActive))
+	return &iam.CreateAccessKeyOutput{AccessKey: &types.AccessKey{
+		AccessKeyId:     aws.String(id),
+		SecretAccessKey: aws.String("secret-" + id),
+		UserName:        params.UserName,
+		Status:          types.StatusTypeActive,
+	}}, nil
+}
+
+func (l *localIAM) UpdateAccessKey(_ context.Context, params *iam.UpdateAccessKeyInput, _ ...func(*iam.Options)) (*iam.UpdateAccessKeyOutput, error) {
+	if err := l.checkUser(params.UserName); err != nil {
+		return nil, err
+	}
+	for i := range l.keys {
+		if *l.keys[i].AccessKeyId == *params.AccessKeyId {
+			l.keys[i].Status = params.Status
+			if params.Status == types.StatusTypeInactive {
+				l.deactivated = append(l.deactivated, *params.AccessKeyId)
+			}
+			return &iam.UpdateAccessKeyOutput{}, nil
+		}
+	}
+	return nil, errors.New("local: no such access key " + *params.AccessKeyId)
+}
+
+func (l *localIAM) DeleteAccessKey(_ context.Context, params *iam.DeleteAccessKeyInput, _ ...func(*iam.Options)) (*iam.DeleteAccessKeyOutput, error) {
+	if err := l.checkUser(params.UserName); err != nil {
+		return nil, err
+	}
+	for i := range l.keys {
+		if *l.keys[i].AccessKeyId == *params.AccessKeyId {
+			l.keys = append(l.keys[:i], l.keys[i+1:]...)
+			l.deleted = append(l.deleted, *params.AccessKeyId)
+			return &iam.DeleteAccessKeyOutput{}, nil
+		}
+	}
+	return nil, &types.NoSuchEntityException{Message: aws.String("local: no such access key " + *params.AccessKeyId)}
+}
+
+// localSTS resolves access key ids to the ARN of their owner, rejecting the first few calls to mimic propagation delay
+type localSTS struct {
+	arns     map[string]string
+	failures int
+	calls    int
+	used     aws.Credentials
+}
+
+func (l *localSTS) client(creds aws.Credentials) iamkey.STSApi {
+	l.used = creds
+	return l
+}
+
+func (l *localSTS) GetCallerIdentity(_ context.Context, _ *sts.GetCallerIdentityInput, _ ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	l.calls++
+	if l.calls <= l.failures {
+		return nil, errors.New("local: InvalidClientTokenId")
+	}
+	arn, ok := l.arns[l.used.AccessKeyID]
+	if !ok {
+		return nil, errors.New("local: InvalidClientTokenId")
+	}
+	return &sts.GetCallerIdentityOutput{Arn: aws.String(arn)}, nil
+}
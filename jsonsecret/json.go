@@ -1,12 +1,12 @@
 package jsonsecret
 
 import (
-	"encoding/json"
 	"github.com/printerlogic/go-secretsmanager-rotate"
 	"reflect"
 )
 
 // Parser returns a rotate.SecretParser that will unmarshal into structs returned by the backing factory function.
+// Structs that embed Unknown will retain any fields of the document they do not declare.
 func Parser(target rotate.Secret) rotate.SecretParser {
 	var ptr bool
 	rt := reflect.TypeOf(target)
@@ -30,7 +30,7 @@ func (p *parser) Parse(s rotate.Secret) (rotate.Secret, error) {
 	}
 
 	target := reflect.New(p.target)
-	err = json.Unmarshal(data, target.Interface())
+	err = Unmarshal(data, target.Interface())
 	if !p.asPtr {
 		return target.Elem().Interface().(rotate.Secret), err
 	}
@@ -0,0 +1,29 @@
+package jsonsecret
+
+import "github.com/printerlogic/go-secretsmanager-rotate"
+
+// For returns a rotate.Codec that decodes JSON secrets into T, for use with rotate.Typed
+// Unknown fields are retained when T embeds Unknown.
+func For[T any]() rotate.Codec[T] {
+	return codec[T]{}
+}
+
+type codec[T any] struct{}
+
+func (codec[T]) Decode(secret rotate.Secret) (T, error) {
+	var value T
+	data, err := secret.Value()
+	if err != nil {
+		return value, err
+	}
+	err = Unmarshal(data, &value)
+	return value, err
+}
+
+func (codec[T]) Encode(value T) (rotate.Secret, error) {
+	data, err := Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return rotate.StringSecret(data), nil
+}
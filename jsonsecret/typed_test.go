@@ -0,0 +1,69 @@
+package jsonsecret_test
+
+import (
+	"context"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/jsonsecret"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	source := rotate.StringSecret(`{"username": "foo", "password": "bar", "host": "db.internal"}`)
+
+	t.Run("decodes into value types", func(t *testing.T) {
+		creds, err := jsonsecret.For[Credentials]().Decode(source)
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", creds.Username)
+		assert.Equal(t, "bar", creds.Password)
+	})
+
+	t.Run("decodes into pointer types and retains unknown fields", func(t *testing.T) {
+		codec := jsonsecret.For[*Credentials]()
+		creds, err := codec.Decode(source)
+		if !assert.NoError(t, err) {
+			return
+		}
+		creds.Password = "baz"
+
+		encoded, err := codec.Encode(creds)
+		if !assert.NoError(t, err) {
+			return
+		}
+		raw, err := encoded.Value()
+		assert.NoError(t, err)
+		assert.False(t, encoded.Binary())
+		assert.JSONEq(t, `{"username": "foo", "password": "baz", "host": "db.internal"}`, string(raw))
+	})
+
+	t.Run("typed services receive decoded structs", func(t *testing.T) {
+		svc := rotate.Typed[Credentials](jsonsecret.For[Credentials](), passwordService{})
+
+		current, err := svc.(rotate.ParsingService).Parse(source)
+		if !assert.NoError(t, err) {
+			return
+		}
+		pending, err := svc.Create(context.TODO(), current)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		raw, err := pending.Value()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"username": "foo", "password": "bar-rotated", "host": "db.internal"}`, string(raw))
+	})
+}
+
+// Credentials does not need to implement rotate.Secret when used with For
+type Credentials struct {
+	jsonsecret.Unknown
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type passwordService struct{}
+
+func (passwordService) Create(_ context.Context, current Credentials) (Credentials, error) {
+	current.Password += "-rotated"
+	return current, nil
+}
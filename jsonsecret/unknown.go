@@ -0,0 +1,155 @@
+package jsonsecret
+
+import (
+	"encoding/json"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"reflect"
+	"strings"
+)
+
+// Unknown can be embedded in a struct to retain any JSON fields the struct does not declare
+// Fields are captured by Unmarshal and written back out by Marshal, so updating a single value of a secret does not
+// erase keys that were added by other consumers. A struct embedding Unknown is best rotated with rotate.Typed and For,
+// which handle the conversion to and from a rotate.Secret, e.g.
+//
+//	rotate.Typed[Credentials](jsonsecret.For[Credentials](), service)
+//
+// Parser also retains unknown fields, but requires the struct to implement rotate.Secret itself.
+type Unknown struct {
+	fields map[string]json.RawMessage
+}
+
+func (u *Unknown) retain(fields map[string]json.RawMessage) {
+	u.fields = fields
+}
+
+func (u Unknown) unknownFields() map[string]json.RawMessage {
+	return u.fields
+}
+
+type retainer interface {
+	retain(map[string]json.RawMessage)
+}
+
+type carrier interface {
+	unknownFields() map[string]json.RawMessage
+}
+
+// Unmarshal decodes data into v, capturing fields v does not declare when it embeds Unknown
+func Unmarshal(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	r, ok := v.(retainer)
+	if !ok {
+		// json.Unmarshal allocates through a pointer to a pointer, such as when decoding into a *T for a pointer type T
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Ptr && !rv.Elem().IsNil() {
+			r, ok = rv.Elem().Interface().(retainer)
+		}
+	}
+	if !ok {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	known := knownFields(reflect.TypeOf(v))
+	for key := range fields {
+		if known[strings.ToLower(key)] {
+			delete(fields, key)
+		}
+	}
+	if len(fields) == 0 {
+		fields = nil
+	}
+	r.retain(fields)
+	return nil
+}
+
+// Marshal encodes v as JSON, including any fields retained by an embedded Unknown
+func Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := v.(carrier)
+	if !ok || len(c.unknownFields()) == 0 {
+		return data, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range c.unknownFields() {
+		if _, ok := fields[key]; !ok {
+			fields[key] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Wrap returns a rotate.Secret whose value is v encoded with Marshal
+// The result cannot be passed back to a Service built with Parser, which expects its own target type, see For instead.
+func Wrap(v interface{}) rotate.Secret {
+	return wrapped{v: v}
+}
+
+type wrapped struct {
+	rotate.Redacted
+	v interface{}
+}
+
+func (w wrapped) Binary() bool {
+	return false
+}
+
+func (w wrapped) Value() ([]byte, error) {
+	return Marshal(w.v)
+}
+
+// knownFields returns the lower cased JSON names of every field encoding/json would decode into for rt
+func knownFields(rt reflect.Type) map[string]bool {
+	known := make(map[string]bool)
+	collectFields(rt, known)
+	return known
+}
+
+func collectFields(rt reflect.Type, known map[string]bool) {
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := tag
+		if idx := strings.IndexByte(tag, ','); idx >= 0 {
+			name = tag[:idx]
+		}
+
+		if field.Anonymous && name == "" {
+			collectFields(field.Type, known)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		known[strings.ToLower(name)] = true
+	}
+}
@@ -0,0 +1,100 @@
+package jsonsecret_test
+
+import (
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/jsonsecret"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnknown(t *testing.T) {
+	source := `{"username": "app", "password": "old", "host": "db.internal", "port": 5432, "options": {"ssl": true}}`
+
+	t.Run("parsed secrets keep fields they do not declare", func(t *testing.T) {
+		secret, err := jsonsecret.Parser(&RetainingSecret{}).Parse(rotate.StringSecret(source))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		creds := secret.(*RetainingSecret)
+		assert.Equal(t, "app", creds.Username)
+		creds.Password = "new"
+
+		raw, err := creds.Value()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"username": "app", "password": "new", "host": "db.internal", "port": 5432, "options": {"ssl": true}}`, string(raw))
+	})
+
+	t.Run("copies of a parsed value secret keep unknown fields", func(t *testing.T) {
+		secret, err := jsonsecret.Parser(RetainingSecret{}).Parse(rotate.StringSecret(source))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		next := secret.(RetainingSecret)
+		next.Password = "new"
+
+		raw, err := jsonsecret.Wrap(next).Value()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"username": "app", "password": "new", "host": "db.internal", "port": 5432, "options": {"ssl": true}}`, string(raw))
+	})
+
+	t.Run("declared fields are matched case insensitively and through embedded structs", func(t *testing.T) {
+		var secret EmbeddingSecret
+		err := jsonsecret.Unmarshal([]byte(`{"USERNAME": "app", "Password": "old", "Region": "us-east-1", "ignored": "yes", "extra": 1}`), &secret)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "app", secret.Username)
+		assert.Equal(t, "us-east-1", secret.Region)
+
+		secret.Username = "renamed"
+		raw, err := jsonsecret.Marshal(&secret)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"username": "renamed", "password": "old", "Region": "us-east-1", "ignored": "yes", "extra": 1}`, string(raw))
+	})
+
+	t.Run("declared fields emptied by the service are not restored", func(t *testing.T) {
+		var secret OptionalSecret
+		if !assert.NoError(t, jsonsecret.Unmarshal([]byte(`{"token": "abc", "note": "keep"}`), &secret)) {
+			return
+		}
+
+		secret.Token = ""
+		raw, err := jsonsecret.Marshal(secret)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"note": "keep"}`, string(raw))
+	})
+
+	t.Run("structs without Unknown marshal normally", func(t *testing.T) {
+		raw, err := jsonsecret.Marshal(SimpleSecret{Username: "a", Password: "b"})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"username": "a", "password": "b"}`, string(raw))
+	})
+}
+
+type RetainingSecret struct {
+	jsonsecret.Unknown
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (s RetainingSecret) Binary() bool {
+	return false
+}
+
+func (s RetainingSecret) Value() ([]byte, error) {
+	return jsonsecret.Marshal(s)
+}
+
+type EmbeddingSecret struct {
+	jsonsecret.Unknown
+	SimpleSecret
+	Region  string
+	Ignored string `json:"-"`
+}
+
+type OptionalSecret struct {
+	jsonsecret.Unknown
+	Token string `json:"token,omitempty"`
+}
@@ -0,0 +1,208 @@
+package keyring
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"io"
+	"time"
+)
+
+// ErrEmpty is returned when a primary key is requested from a Keyring without any keys
+var ErrEmpty = errors.New("keyring: no keys available")
+
+// Key is a single versioned symmetric key held within a Keyring
+type Key struct {
+	rotate.Redacted
+
+	// Version uniquely identifies the key within its Keyring, newer keys have higher versions
+	Version int `json:"version"`
+
+	// Secret is the raw key material, stored as base64 when serialized
+	Secret []byte `json:"secret"`
+
+	// Created is the time the key was generated
+	Created time.Time `json:"created"`
+}
+
+// Sign returns the HMAC-SHA256 of message using the key
+func (k Key) Sign(message []byte) []byte {
+	mac := hmac.New(sha256.New, k.Secret)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// Verify reports whether signature is a valid HMAC-SHA256 of message for the key
+func (k Key) Verify(message, signature []byte) bool {
+	return hmac.Equal(k.Sign(message), signature)
+}
+
+// Keyring is an ordered list of keys stored as a single JSON secret
+// Keys are ordered newest first, and the first key is the primary key used for new signatures.
+type Keyring struct {
+	rotate.Redacted
+	Keys []Key `json:"keys"`
+}
+
+func (k *Keyring) Binary() bool {
+	return false
+}
+
+func (k *Keyring) Value() ([]byte, error) {
+	return json.Marshal(k)
+}
+
+// Destroy overwrites the material of every key with zeroes
+func (k *Keyring) Destroy() {
+	for _, key := range k.Keys {
+		for i := range key.Secret {
+			key.Secret[i] = 0
+		}
+	}
+}
+
+// Ensure that *Keyring remains DestroyableSecret compatible
+func _(k *Keyring) rotate.DestroyableSecret {
+	return k
+}
+
+// Primary returns the key that should be used when producing new signatures
+func (k *Keyring) Primary() (Key, error) {
+	if len(k.Keys) == 0 {
+		return Key{}, ErrEmpty
+	}
+	return k.Keys[0], nil
+}
+
+// Lookup returns the key with the given version, if it is still held in the keyring
+func (k *Keyring) Lookup(version int) (Key, bool) {
+	for _, key := range k.Keys {
+		if key.Version == version {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Sign returns the HMAC-SHA256 of message using the primary key, along with the key that was used
+func (k *Keyring) Sign(message []byte) (Key, []byte, error) {
+	primary, err := k.Primary()
+	if err != nil {
+		return Key{}, nil, err
+	}
+	return primary, primary.Sign(message), nil
+}
+
+// Verify checks signature against every key in the keyring and returns the first key that produced it
+// This allows signatures made with a recently replaced key to continue to verify.
+func (k *Keyring) Verify(message, signature []byte) (Key, bool) {
+	for _, key := range k.Keys {
+		if key.Verify(message, signature) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Prune drops the oldest keys so that at most max keys remain
+func (k *Keyring) Prune(max int) {
+	if max >= 0 && len(k.Keys) > max {
+		k.Keys = k.Keys[:max]
+	}
+}
+
+// Decode reads a Keyring from its serialized form, an empty document is treated as an empty keyring
+// Consumers of the secret can use this to select the primary key and verify signatures.
+func Decode(data []byte) (*Keyring, error) {
+	keyring := &Keyring{}
+	if len(data) == 0 {
+		return keyring, nil
+	}
+	return keyring, json.Unmarshal(data, keyring)
+}
+
+func (k *Keyring) nextVersion() int {
+	var version int
+	for _, key := range k.Keys {
+		if key.Version > version {
+			version = key.Version
+		}
+	}
+	return version + 1
+}
+
+type Config struct {
+	// KeyLength is the number of random bytes generated for each new key, defaults to 32
+	KeyLength int
+
+	// MaxKeys is the maximum number of keys kept in the keyring, defaults to 3
+	MaxKeys int
+
+	// Random is the source of new key material, defaults to crypto/rand.Reader
+	Random io.Reader
+}
+
+// Service returns a rotate.Service that rotates a Keyring by prepending a newly generated key
+// Secrets Manager versions cannot be modified once written, so the keyring is pruned to MaxKeys when the pending
+// version is created. The pending keyring is what becomes AWSCURRENT once the rotation is finished.
+func Service(c Config) rotate.Service {
+	if c.KeyLength <= 0 {
+		c.KeyLength = 32
+	}
+	if c.MaxKeys <= 0 {
+		c.MaxKeys = 3
+	}
+	if c.Random == nil {
+		c.Random = rand.Reader
+	}
+	return &service{
+		keyLength: c.KeyLength,
+		maxKeys:   c.MaxKeys,
+		random:    c.Random,
+		now:       time.Now,
+	}
+}
+
+type service struct {
+	keyLength int
+	maxKeys   int
+	random    io.Reader
+	now       func() time.Time
+}
+
+func (s *service) Create(_ context.Context, current rotate.Secret) (rotate.Secret, error) {
+	keyring, ok := current.(*Keyring)
+	if !ok {
+		return nil, fmt.Errorf("keyring: unexpected current secret type %T", current)
+	}
+
+	secret := make([]byte, s.keyLength)
+	if _, err := io.ReadFull(s.random, secret); err != nil {
+		return nil, fmt.Errorf("keyring: generating key: %w", err)
+	}
+
+	pending := &Keyring{
+		Keys: make([]Key, 0, len(keyring.Keys)+1),
+	}
+	pending.Keys = append(pending.Keys, Key{
+		Version: keyring.nextVersion(),
+		Secret:  secret,
+		Created: s.now().UTC(),
+	})
+	pending.Keys = append(pending.Keys, keyring.Keys...)
+	pending.Prune(s.maxKeys)
+	return pending, nil
+}
+
+func (s *service) Parse(secret rotate.Secret) (rotate.Secret, error) {
+	data, err := secret.Value()
+	if err != nil {
+		return secret, err
+	}
+	return Decode(data)
+}
@@ -0,0 +1,147 @@
+package keyring_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/keyring"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestService(t *testing.T) {
+	t.Run("create prepends a new key to an empty keyring", func(t *testing.T) {
+		svc := keyring.Service(keyring.Config{KeyLength: 16})
+
+		current, err := svc.(rotate.ParsingService).Parse(rotate.StringSecret(""))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		pending, err := svc.Create(context.TODO(), current)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		ring := pending.(*keyring.Keyring)
+		if !assert.Len(t, ring.Keys, 1) {
+			return
+		}
+		assert.Equal(t, 1, ring.Keys[0].Version)
+		assert.Len(t, ring.Keys[0].Secret, 16)
+	})
+
+	t.Run("create retains previous keys behind the new primary", func(t *testing.T) {
+		svc := keyring.Service(keyring.Config{Random: bytes.NewReader(bytes.Repeat([]byte{7}, 32))})
+		current := &keyring.Keyring{Keys: []keyring.Key{
+			{Version: 4, Secret: []byte("four")},
+			{Version: 3, Secret: []byte("three")},
+		}}
+
+		pending, err := svc.Create(context.TODO(), current)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		ring := pending.(*keyring.Keyring)
+		if !assert.Len(t, ring.Keys, 3) {
+			return
+		}
+		assert.Equal(t, 5, ring.Keys[0].Version)
+		assert.Equal(t, bytes.Repeat([]byte{7}, 32), ring.Keys[0].Secret)
+		assert.Equal(t, 4, ring.Keys[1].Version)
+		assert.Equal(t, 3, ring.Keys[2].Version)
+		// the current keyring must not be modified by create
+		assert.Len(t, current.Keys, 2)
+	})
+
+	t.Run("create prunes the oldest keys beyond MaxKeys", func(t *testing.T) {
+		svc := keyring.Service(keyring.Config{MaxKeys: 2})
+		current := &keyring.Keyring{Keys: []keyring.Key{
+			{Version: 2, Secret: []byte("two")},
+			{Version: 1, Secret: []byte("one")},
+		}}
+
+		pending, err := svc.Create(context.TODO(), current)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		ring := pending.(*keyring.Keyring)
+		if !assert.Len(t, ring.Keys, 2) {
+			return
+		}
+		assert.Equal(t, 3, ring.Keys[0].Version)
+		assert.Equal(t, 2, ring.Keys[1].Version)
+	})
+
+	t.Run("created keyring survives serialization", func(t *testing.T) {
+		svc := keyring.Service(keyring.Config{})
+		pending, err := svc.Create(context.TODO(), &keyring.Keyring{})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		raw, err := pending.Value()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		parsed, err := svc.(rotate.ParsingService).Parse(rotate.StringSecret(raw))
+		assert.NoError(t, err)
+		assert.Equal(t, pending, parsed)
+	})
+
+	t.Run("create fails for an unparsed secret", func(t *testing.T) {
+		svc := keyring.Service(keyring.Config{})
+		_, err := svc.Create(context.TODO(), rotate.StringSecret(`{"keys": []}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyring(t *testing.T) {
+	message := []byte("GET /api/v1/printers")
+
+	t.Run("primary is unavailable for an empty keyring", func(t *testing.T) {
+		_, err := (&keyring.Keyring{}).Primary()
+		assert.ErrorIs(t, err, keyring.ErrEmpty)
+	})
+
+	t.Run("signatures from previous keys continue to verify", func(t *testing.T) {
+		previous := &keyring.Keyring{Keys: []keyring.Key{{Version: 1, Secret: []byte("first")}}}
+		_, signature, err := previous.Sign(message)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		pending, err := keyring.Service(keyring.Config{}).Create(context.TODO(), previous)
+		if !assert.NoError(t, err) {
+			return
+		}
+		current := pending.(*keyring.Keyring)
+
+		key, ok := current.Verify(message, signature)
+		assert.True(t, ok)
+		assert.Equal(t, 1, key.Version)
+
+		primary, err := current.Primary()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, primary.Version)
+		assert.False(t, primary.Verify(message, signature))
+	})
+
+	t.Run("signatures from pruned keys are rejected", func(t *testing.T) {
+		ring := &keyring.Keyring{Keys: []keyring.Key{
+			{Version: 2, Secret: []byte("second")},
+			{Version: 1, Secret: []byte("first")},
+		}}
+		old, _ := ring.Lookup(1)
+		signature := old.Sign(message)
+
+		ring.Prune(1)
+		_, ok := ring.Verify(message, signature)
+		assert.False(t, ok)
+		_, ok = ring.Lookup(1)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,99 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Locker leases secrets to a single rotation at a time, implementations must be safe for concurrent use
+// The owner of a lease is the ClientRequestToken of the rotation holding it.
+type Locker interface {
+	// Lock acquires the lease on secretId for owner until ttl has passed, renewing it when owner already holds it
+	// A *LockedError is returned when another owner holds a lease that has not expired.
+	Lock(ctx context.Context, secretId, owner string, ttl time.Duration) error
+
+	// Unlock releases the lease on secretId when it is held by owner
+	Unlock(ctx context.Context, secretId, owner string) error
+}
+
+// LockedError reports that a secret is leased to another rotation
+type LockedError struct {
+	SecretId string
+
+	// Owner is the ClientRequestToken of the rotation holding the lease
+	Owner   string
+	Expires time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("secret %s is being rotated by %s until %s", e.SecretId, e.Owner, e.Expires.UTC().Format(time.RFC3339))
+}
+
+// MemoryLocker is a Locker for rotations handled within a single process, the zero value is ready to use
+type MemoryLocker struct {
+	mu     sync.Mutex
+	leases map[string]lease
+	now    func() time.Time
+}
+
+type lease struct {
+	owner   string
+	expires time.Time
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{leases: make(map[string]lease), now: time.Now}
+}
+
+func (m *MemoryLocker) Lock(_ context.Context, secretId, owner string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.now != nil {
+		now = m.now()
+	}
+	if m.leases == nil {
+		m.leases = make(map[string]lease)
+	}
+	if held, ok := m.leases[secretId]; ok && held.owner != owner && now.Before(held.expires) {
+		return &LockedError{SecretId: secretId, Owner: held.owner, Expires: held.expires}
+	}
+	m.leases[secretId] = lease{owner: owner, expires: now.Add(ttl)}
+	return nil
+}
+
+func (m *MemoryLocker) Unlock(_ context.Context, secretId, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if held, ok := m.leases[secretId]; ok && held.owner == owner {
+		delete(m.leases, secretId)
+	}
+	return nil
+}
+
+// lock leases the secret to the rotation of event, the lease is taken by each step and only released once the
+// rotation finishes, so that another rotation cannot interleave its steps
+func (r *rotator) lock(ctx context.Context, event Event) error {
+	if r.locker == nil {
+		return nil
+	}
+	ctx, cancel := r.network(ctx)
+	defer cancel()
+	return r.locker.Lock(ctx, event.SecretId, event.ClientRequestToken, r.lockTTL)
+}
+
+// unlock releases the lease once the rotation of event is complete, a lease that cannot be released is left to expire
+func (r *rotator) unlock(ctx context.Context, event Event) {
+	if r.locker == nil {
+		return
+	}
+	ctx, cancel := r.network(ctx)
+	defer cancel()
+	if err := r.locker.Unlock(ctx, event.SecretId, event.ClientRequestToken); err != nil {
+		r.logger.Printf("Failed to release the lease on %s, it will expire instead: %s", event.SecretId, err)
+	}
+}
@@ -0,0 +1,69 @@
+package rotate
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryLocker(t *testing.T) {
+	locker := NewMemoryLocker()
+	now := time.Now()
+	locker.now = func() time.Time { return now }
+	ctx := context.TODO()
+
+	assert.NoError(t, locker.Lock(ctx, "my-secret", "scheduled", time.Minute))
+	assert.NoError(t, locker.Lock(ctx, "my-secret", "scheduled", time.Minute), "the owner should renew its lease")
+	assert.NoError(t, locker.Lock(ctx, "other-secret", "manual", time.Minute), "leases should be per secret")
+
+	err := locker.Lock(ctx, "my-secret", "manual", time.Minute)
+	var locked *LockedError
+	if assert.ErrorAs(t, err, &locked) {
+		assert.Equal(t, &LockedError{SecretId: "my-secret", Owner: "scheduled", Expires: now.Add(time.Minute)}, locked)
+	}
+
+	assert.NoError(t, locker.Unlock(ctx, "my-secret", "manual"))
+	assert.Error(t, locker.Lock(ctx, "my-secret", "manual", time.Minute), "only the owner should release a lease")
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, locker.Lock(ctx, "my-secret", "manual", time.Minute), "an expired lease should be taken over")
+
+	assert.NoError(t, locker.Unlock(ctx, "my-secret", "manual"))
+	assert.NoError(t, locker.Lock(ctx, "my-secret", "scheduled", time.Minute))
+}
+
+func TestMemoryLockerZeroValue(t *testing.T) {
+	var locker MemoryLocker
+	ctx := context.TODO()
+
+	assert.NoError(t, locker.Unlock(ctx, "my-secret", "scheduled"))
+	assert.NoError(t, locker.Lock(ctx, "my-secret", "scheduled", time.Minute))
+	assert.Error(t, locker.Lock(ctx, "my-secret", "manual", time.Minute))
+}
+
+func TestRotatorLocking(t *testing.T) {
+	sm := newFakeSecretsManager(map[string]string{"my-secret": "v0"})
+	r := testRotator(t, sm, &mockService{OnCreate: StringSecret("v1")}).(*rotator)
+	r.locker = NewMemoryLocker()
+	r.lockTTL = time.Minute
+
+	scheduled := Event{SecretId: "my-secret", ClientRequestToken: "scheduled"}
+	manual := Event{SecretId: "my-secret", ClientRequestToken: "manual"}
+	step := func(event Event, step Step) error {
+		event.Step = step
+		return r.Handle(context.TODO(), event)
+	}
+
+	assert.NoError(t, step(scheduled, StepCreate))
+	assert.IsType(t, &LockedError{}, step(manual, StepCreate))
+	assert.Equal(t, map[string][]string{"initial": {AWSCURRENT}, "scheduled": {AWSPENDING}}, sm.Stages("my-secret"), "the rejected rotation should not create a version")
+
+	assert.NoError(t, step(scheduled, StepSet))
+	assert.NoError(t, step(scheduled, StepTest))
+	assert.IsType(t, &LockedError{}, step(manual, StepSet))
+	assert.NoError(t, step(scheduled, StepFinish))
+
+	// the lease is released once the rotation finishes
+	assert.NoError(t, step(manual, StepCreate))
+}
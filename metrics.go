@@ -0,0 +1,30 @@
+package rotate
+
+import "time"
+
+// Outcome is the result of handling a step
+type Outcome string
+
+const (
+	// OutcomeSuccess is a step that completed its work
+	OutcomeSuccess Outcome = "success"
+
+	// OutcomeFailure is a step that returned an error
+	OutcomeFailure Outcome = "failure"
+
+	// OutcomeSkipped is a step that had already been completed, such as a retried event for a version that is already
+	// AWSCURRENT
+	OutcomeSkipped Outcome = "skipped"
+)
+
+// Metrics receives measurements from the rotator, implementations must be safe for concurrent use
+type Metrics interface {
+	// StepCompleted is called once for every handled event
+	StepCompleted(event Event, outcome Outcome, duration time.Duration)
+
+	// HookCompleted is called after each call into the Service, err is the error returned by the hook
+	HookCompleted(event Event, err error, duration time.Duration)
+
+	// APICallCompleted is called after each Secrets Manager API call, operation is the API name e.g. GetSecretValue
+	APICallCompleted(secretId string, operation string, err error, duration time.Duration)
+}
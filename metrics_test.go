@@ -0,0 +1,120 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("successful step", func(t *testing.T) {
+		event := testEvent(StepCreate)
+		currentValue := "current"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &currentValue},
+			},
+		}
+		metrics := &recordingMetrics{}
+		r := testRotator(t, sm, &mockService{OnCreate: StringSecret("new")}).(*rotator)
+		r.metrics = metrics
+
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assert.Equal(t, []string{"createSecret success"}, metrics.Steps)
+		assert.Equal(t, []string{"createSecret <nil>"}, metrics.Hooks)
+		// a missing AWSPENDING stage is not a failed lookup
+		assert.Equal(t, []string{"GetSecretValue <nil>", "GetSecretValue <nil>", "PutSecretValue <nil>"}, metrics.APICalls)
+	})
+
+	t.Run("skipped step", func(t *testing.T) {
+		event := testEvent(StepFinish)
+		currentValue := "current"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: &event.ClientRequestToken, SecretString: &currentValue},
+			},
+		}
+		metrics := &recordingMetrics{}
+		r := testRotator(t, sm, &mockService{}).(*rotator)
+		r.metrics = metrics
+
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assert.Equal(t, []string{"finishSecret skipped"}, metrics.Steps)
+		assert.Empty(t, metrics.Hooks)
+		assert.Equal(t, []string{"GetSecretValue <nil>"}, metrics.APICalls)
+	})
+
+	t.Run("failed hook", func(t *testing.T) {
+		event := testEvent(StepTest)
+		pendingValue := "pending"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSPENDING: {VersionId: &event.ClientRequestToken, SecretString: &pendingValue},
+			},
+		}
+		metrics := &recordingMetrics{}
+		r := testRotator(t, sm, &failingService{err: errors.New("rejected")}).(*rotator)
+		r.metrics = metrics
+
+		assert.Error(t, r.Handle(context.TODO(), event))
+		assert.Equal(t, []string{"testSecret failure"}, metrics.Steps)
+		assert.Equal(t, []string{"testSecret rejected"}, metrics.Hooks)
+	})
+
+	t.Run("failed api call", func(t *testing.T) {
+		metrics := &recordingMetrics{}
+		r := testRotator(t, &mockSecretsManager{}, &mockService{}).(*rotator)
+		r.metrics = metrics
+
+		assert.Error(t, r.Handle(context.TODO(), testEvent(StepCreate)))
+		assert.Equal(t, []string{"createSecret failure"}, metrics.Steps)
+		if assert.Len(t, metrics.APICalls, 1) {
+			assert.NotEqual(t, "GetSecretValue <nil>", metrics.APICalls[0])
+		}
+	})
+}
+
+type recordingMetrics struct {
+	sync.Mutex
+	Steps    []string
+	Hooks    []string
+	APICalls []string
+}
+
+func (m *recordingMetrics) StepCompleted(event Event, outcome Outcome, _ time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.Steps = append(m.Steps, string(event.Step)+" "+string(outcome))
+}
+
+func (m *recordingMetrics) HookCompleted(event Event, err error, _ time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.Hooks = append(m.Hooks, string(event.Step)+" "+errorString(err))
+}
+
+func (m *recordingMetrics) APICallCompleted(_ string, operation string, err error, _ time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.APICalls = append(m.APICalls, operation+" "+errorString(err))
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return err.Error()
+}
+
+type failingService struct {
+	mockService
+	err error
+}
+
+func (f *failingService) Test(context.Context, Secret) error {
+	return f.err
+}
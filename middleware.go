@@ -0,0 +1,95 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps a Handler with behaviour that applies to every step, such as recovery, timing or authorization
+type Middleware func(next Handler) Handler
+
+// HandlerFunc adapts an ordinary function to a Handler
+type HandlerFunc func(context.Context, Event) error
+
+func (f HandlerFunc) Handle(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Hook is a single call into the Service, such as Create or Rollback
+type Hook func(ctx context.Context) error
+
+// HookMiddleware wraps the Service hook called while handling event, name is the Service method being called: Create,
+// Set, Test, Finish, Clean or Rollback
+// Unlike Middleware it is only invoked when the rotator actually calls into the Service, so steps that are skipped
+// because the rotation has already progressed, or because the Service does not implement the hook, are not wrapped.
+type HookMiddleware func(event Event, name string, next Hook) Hook
+
+// hookName is the Service method called during step
+func hookName(step Step) string {
+	switch step {
+	case StepCreate:
+		return "Create"
+	case StepSet:
+		return "Set"
+	case StepTest:
+		return "Test"
+	case StepFinish:
+		return "Finish"
+	}
+	return string(step)
+}
+
+// wrap applies middleware to handler so that the first middleware is the outermost
+func wrap(handler Handler, middleware []Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// PanicError is returned by Recover when a step panics
+type PanicError struct {
+	Step  Step
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic during %s step: %v", p.Step, p.Value)
+}
+
+// Recover returns a Middleware that converts a panic while handling a step into a *PanicError
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, event Event) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = &PanicError{Step: event.Step, Value: p, Stack: debug.Stack()}
+				}
+			}()
+			return next.Handle(ctx, event)
+		})
+	}
+}
+
+// Timing returns a Middleware that logs how long each step took, logger defaults to log.Default()
+func Timing(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, event Event) error {
+			start := time.Now()
+			err := next.Handle(ctx, event)
+			if err != nil {
+				logger.Printf("[%s] failed for secret: %s after %s", event.Step, event.SecretId, time.Since(start))
+			} else {
+				logger.Printf("[%s] completed for secret: %s in %s", event.Step, event.SecretId, time.Since(start))
+			}
+			return err
+		})
+	}
+}
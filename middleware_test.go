@@ -0,0 +1,159 @@
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Run("first middleware is outermost", func(t *testing.T) {
+		var calls []string
+		record := func(name string) Middleware {
+			return func(next Handler) Handler {
+				return HandlerFunc(func(ctx context.Context, event Event) error {
+					calls = append(calls, name+" before")
+					err := next.Handle(ctx, event)
+					calls = append(calls, name+" after")
+					return err
+				})
+			}
+		}
+
+		handler := wrap(HandlerFunc(func(context.Context, Event) error {
+			calls = append(calls, "handler")
+			return nil
+		}), []Middleware{record("outer"), record("inner")})
+
+		assert.NoError(t, handler.Handle(context.TODO(), testEvent(StepCreate)))
+		assert.Equal(t, []string{"outer before", "inner before", "handler", "inner after", "outer after"}, calls)
+	})
+
+	t.Run("middleware can reject a step", func(t *testing.T) {
+		denied := errors.New("denied")
+		sm := &mockSecretsManager{}
+		handler := New(Config{
+			SecretsManager: sm,
+			Service:        &mockService{},
+			Middleware: []Middleware{func(Handler) Handler {
+				return HandlerFunc(func(context.Context, Event) error {
+					return denied
+				})
+			}},
+		})
+
+		assert.ErrorIs(t, handler.Handle(context.TODO(), testEvent(StepCreate)), denied)
+		assertApiCounts(t, sm, apiCounts{})
+	})
+
+	t.Run("hook middleware wraps service calls", func(t *testing.T) {
+		event := testEvent(StepCreate)
+		currentValue := "current"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &currentValue},
+			},
+		}
+		svc := &mockService{OnCreate: StringSecret("new")}
+
+		var calls []string
+		r := testRotator(t, sm, svc).(*rotator)
+		r.hookMiddleware = []HookMiddleware{
+			func(event Event, name string, next Hook) Hook {
+				return func(ctx context.Context) error {
+					calls = append(calls, "outer "+string(event.Step)+" "+name)
+					return next(ctx)
+				}
+			},
+			func(event Event, name string, next Hook) Hook {
+				return func(ctx context.Context) error {
+					calls = append(calls, "inner "+string(event.Step)+" "+name)
+					assert.Empty(t, svc.CreateCalled, "hook middleware should run before the service")
+					return next(ctx)
+				}
+			},
+		}
+
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assert.Equal(t, []string{"outer " + string(StepCreate) + " Create", "inner " + string(StepCreate) + " Create"}, calls)
+		assertApiCounts(t, sm, apiCounts{Lookups: 2, Creates: 1})
+		assertServiceCounts(t, svc, serviceCounts{Parses: 1, Creates: 1})
+	})
+
+	t.Run("hook middleware errors stop the step", func(t *testing.T) {
+		event := testEvent(StepFinish)
+		currentValue, pendingValue := "current", "pending"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &currentValue},
+				AWSPENDING: {VersionId: &event.ClientRequestToken, SecretString: &pendingValue},
+			},
+		}
+		svc := &mockService{}
+
+		failed := errors.New("failed")
+		r := testRotator(t, sm, svc).(*rotator)
+		r.hookMiddleware = []HookMiddleware{func(Event, string, Hook) Hook {
+			return func(context.Context) error {
+				return failed
+			}
+		}}
+
+		assert.ErrorIs(t, r.Handle(context.TODO(), event), failed)
+		// the service was not called and the secret was not promoted
+		assertApiCounts(t, sm, apiCounts{Lookups: 2})
+		assertServiceCounts(t, svc, serviceCounts{Parses: 2})
+	})
+
+	t.Run("hook middleware is skipped when the step is already complete", func(t *testing.T) {
+		event := testEvent(StepCreate)
+		currentValue := "current"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: &event.ClientRequestToken, SecretString: &currentValue},
+			},
+		}
+
+		r := testRotator(t, sm, &mockService{}).(*rotator)
+		r.hookMiddleware = []HookMiddleware{func(Event, string, Hook) Hook {
+			t.Error("hook middleware should not be called")
+			return nil
+		}}
+		assert.NoError(t, r.Handle(context.TODO(), event))
+	})
+}
+
+func TestRecover(t *testing.T) {
+	handler := Recover()(HandlerFunc(func(context.Context, Event) error {
+		panic("boom")
+	}))
+
+	err := handler.Handle(context.TODO(), testEvent(StepTest))
+	var panicErr *PanicError
+	if assert.ErrorAs(t, err, &panicErr) {
+		assert.Equal(t, StepTest, panicErr.Step)
+		assert.Equal(t, "boom", panicErr.Value)
+		assert.NotEmpty(t, panicErr.Stack)
+		assert.Equal(t, "panic during testSecret step: boom", err.Error())
+	}
+}
+
+func TestTiming(t *testing.T) {
+	var logOutput bytes.Buffer
+	failed := errors.New("failed")
+	handler := Timing(log.New(&logOutput, "", 0))(HandlerFunc(func(_ context.Context, event Event) error {
+		if event.Step == StepFinish {
+			return failed
+		}
+		return nil
+	}))
+
+	assert.NoError(t, handler.Handle(context.TODO(), testEvent(StepCreate)))
+	assert.ErrorIs(t, handler.Handle(context.TODO(), testEvent(StepFinish)), failed)
+	assert.Contains(t, logOutput.String(), "[createSecret] completed for secret: ")
+	assert.Contains(t, logOutput.String(), "[finishSecret] failed for secret: ")
+}
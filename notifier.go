@@ -0,0 +1,119 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// NotificationKind identifies the point in a rotation that a Notification describes
+type NotificationKind string
+
+const (
+	// StepStarted is sent before a step is handled
+	StepStarted NotificationKind = "stepStarted"
+
+	// StepSucceeded is sent after a step is handled without error, including steps that had already been completed
+	StepSucceeded NotificationKind = "stepSucceeded"
+
+	// StepFailed is sent after a step returns an error
+	StepFailed NotificationKind = "stepFailed"
+
+	// RotationCompleted is sent once the finish step has promoted the pending version to AWSCURRENT
+	RotationCompleted NotificationKind = "rotationCompleted"
+)
+
+// Notification describes a point in the lifecycle of a rotation
+type Notification struct {
+	Kind  NotificationKind
+	Event Event
+	Time  time.Time
+
+	// Skipped is set on StepSucceeded when the step had already been completed
+	Skipped bool
+
+	// Err is the error returned by the step for StepFailed
+	Err error
+}
+
+// Notifier receives lifecycle notifications, implementations must be safe for concurrent use
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// NotifyMode controls how notifications are delivered, and whether a failed delivery fails the step
+type NotifyMode int
+
+const (
+	// NotifyAsync delivers notifications in the background and logs failures, the step is never delayed
+	// A Lambda execution environment may be frozen once the handler returns, delaying delivery until the next
+	// invocation, so prefer NotifySync when running on Lambda.
+	NotifyAsync NotifyMode = iota
+
+	// NotifySync waits for each notification to be delivered and logs failures
+	NotifySync
+
+	// NotifyStrict waits for each notification to be delivered and fails the step when delivery fails
+	NotifyStrict
+)
+
+// notify delivers a notification according to the configured NotifyMode
+// An error is only returned for NotifyStrict.
+func (r *rotator) notify(ctx context.Context, notification Notification) error {
+	if r.notifier == nil {
+		return nil
+	}
+	notification.Time = time.Now()
+
+	if r.notifyMode == NotifyAsync {
+		ctx = context.WithoutCancel(ctx)
+		go func() {
+			if err := r.notifier.Notify(ctx, notification); err != nil {
+				r.logger.Printf("Failed to deliver %s notification: %s", notification.Kind, err)
+			}
+		}()
+		return nil
+	}
+
+	err := r.notifier.Notify(ctx, notification)
+	if err == nil {
+		return nil
+	}
+	if r.notifyMode == NotifyStrict {
+		return &NotifyError{Kind: notification.Kind, Err: err}
+	}
+	r.logger.Printf("Failed to deliver %s notification: %s", notification.Kind, err)
+	return nil
+}
+
+// NotifyError is returned by a step when a notification could not be delivered with NotifyStrict
+type NotifyError struct {
+	Kind NotificationKind
+	Err  error
+}
+
+func (n *NotifyError) Error() string {
+	return "delivering " + string(n.Kind) + " notification: " + n.Err.Error()
+}
+
+func (n *NotifyError) Unwrap() error {
+	return n.Err
+}
+
+// notifyCompleted sends the notifications that follow a handled step, joining any delivery error with err
+func (r *rotator) notifyCompleted(ctx context.Context, event Event, skipped bool, err error) error {
+	if err != nil {
+		if notifyErr := r.notify(ctx, Notification{Kind: StepFailed, Event: event, Err: err}); notifyErr != nil {
+			return errors.Join(err, notifyErr)
+		}
+		return err
+	}
+
+	if err = r.notify(ctx, Notification{Kind: StepSucceeded, Event: event, Skipped: skipped}); err != nil {
+		return err
+	}
+	if event.Step == StepFinish && !skipped {
+		return r.notify(ctx, Notification{Kind: RotationCompleted, Event: event})
+	}
+	return nil
+}
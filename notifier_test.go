@@ -0,0 +1,146 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotifier(t *testing.T) {
+	finishSecretsManager := func(event Event) *mockSecretsManager {
+		currentValue, pendingValue := "current", "pending"
+		return &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &currentValue},
+				AWSPENDING: {VersionId: &event.ClientRequestToken, SecretString: &pendingValue},
+			},
+		}
+	}
+
+	t.Run("finish notifies completion", func(t *testing.T) {
+		event := testEvent(StepFinish)
+		notifier := &recordingNotifier{}
+		r := testRotator(t, finishSecretsManager(event), &mockService{}).(*rotator)
+		r.notifier, r.notifyMode = notifier, NotifySync
+
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assert.Equal(t, []NotificationKind{StepStarted, StepSucceeded, RotationCompleted}, notifier.kinds())
+		for _, n := range notifier.Notifications {
+			assert.Equal(t, event, n.Event)
+			assert.False(t, n.Time.IsZero())
+		}
+	})
+
+	t.Run("skipped finish does not notify completion", func(t *testing.T) {
+		event := testEvent(StepFinish)
+		currentValue := "current"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: &event.ClientRequestToken, SecretString: &currentValue},
+			},
+		}
+		notifier := &recordingNotifier{}
+		r := testRotator(t, sm, &mockService{}).(*rotator)
+		r.notifier, r.notifyMode = notifier, NotifySync
+
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		if assert.Equal(t, []NotificationKind{StepStarted, StepSucceeded}, notifier.kinds()) {
+			assert.True(t, notifier.Notifications[1].Skipped)
+		}
+	})
+
+	t.Run("failed step", func(t *testing.T) {
+		notifier := &recordingNotifier{}
+		r := testRotator(t, &mockSecretsManager{}, &mockService{}).(*rotator)
+		r.notifier, r.notifyMode = notifier, NotifySync
+
+		err := r.Handle(context.TODO(), testEvent(StepCreate))
+		assert.Error(t, err)
+		if assert.Equal(t, []NotificationKind{StepStarted, StepFailed}, notifier.kinds()) {
+			assert.Equal(t, err, notifier.Notifications[1].Err)
+		}
+	})
+
+	t.Run("sync delivery failures are logged", func(t *testing.T) {
+		event := testEvent(StepFinish)
+		sm := finishSecretsManager(event)
+		notifier := &recordingNotifier{err: errors.New("unreachable")}
+		r := testRotator(t, sm, &mockService{}).(*rotator)
+		r.notifier, r.notifyMode = notifier, NotifySync
+
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assertApiCounts(t, sm, apiCounts{Lookups: 2, Promotes: 1})
+	})
+
+	t.Run("strict delivery failures fail the step", func(t *testing.T) {
+		event := testEvent(StepFinish)
+		sm := finishSecretsManager(event)
+		unreachable := errors.New("unreachable")
+		notifier := &recordingNotifier{err: unreachable}
+		r := testRotator(t, sm, &mockService{}).(*rotator)
+		r.notifier, r.notifyMode = notifier, NotifyStrict
+
+		err := r.Handle(context.TODO(), event)
+		var notifyErr *NotifyError
+		if assert.ErrorAs(t, err, &notifyErr) {
+			assert.Equal(t, StepStarted, notifyErr.Kind)
+			assert.ErrorIs(t, err, unreachable)
+		}
+		// the step is not attempted when it could not be announced
+		assertApiCounts(t, sm, apiCounts{})
+	})
+
+	t.Run("async delivery does not block", func(t *testing.T) {
+		event := testEvent(StepFinish)
+		release := make(chan struct{})
+		notifier := &recordingNotifier{block: release}
+		r := testRotator(t, finishSecretsManager(event), &mockService{}).(*rotator)
+		r.notifier = notifier
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		assert.NoError(t, r.Handle(ctx, event))
+		cancel()
+		close(release)
+
+		assert.Eventually(t, func() bool {
+			return len(notifier.kinds()) == 3
+		}, time.Second, time.Millisecond)
+		assert.ElementsMatch(t, []NotificationKind{StepStarted, StepSucceeded, RotationCompleted}, notifier.kinds())
+		assert.NoError(t, notifier.ctxErr, "async notifications should not be cancelled with the step")
+	})
+}
+
+type recordingNotifier struct {
+	sync.Mutex
+	Notifications []Notification
+	err           error
+	block         chan struct{}
+	ctxErr        error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification Notification) error {
+	if n.block != nil {
+		<-n.block
+	}
+	n.Lock()
+	defer n.Unlock()
+	n.Notifications = append(n.Notifications, notification)
+	if err := ctx.Err(); err != nil {
+		n.ctxErr = err
+	}
+	return n.err
+}
+
+func (n *recordingNotifier) kinds() []NotificationKind {
+	n.Lock()
+	defer n.Unlock()
+	var kinds []NotificationKind
+	for _, notification := range n.Notifications {
+		kinds = append(kinds, notification.Kind)
+	}
+	return kinds
+}
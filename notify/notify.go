@@ -0,0 +1,71 @@
+// Package notify delivers rotation lifecycle notifications to webhooks, Slack and SNS
+package notify
+
+import (
+	"context"
+	"errors"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"time"
+)
+
+// Payload is the JSON representation of a rotate.Notification
+type Payload struct {
+	Kind               rotate.NotificationKind `json:"kind"`
+	SecretId           string                  `json:"secretId"`
+	Step               rotate.Step             `json:"step"`
+	ClientRequestToken string                  `json:"clientRequestToken"`
+	Time               time.Time               `json:"time"`
+	Skipped            bool                    `json:"skipped,omitempty"`
+	Error              string                  `json:"error,omitempty"`
+}
+
+// NewPayload converts a notification into its JSON representation
+func NewPayload(n rotate.Notification) Payload {
+	p := Payload{
+		Kind:               n.Kind,
+		SecretId:           n.Event.SecretId,
+		Step:               n.Event.Step,
+		ClientRequestToken: n.Event.ClientRequestToken,
+		Time:               n.Time.UTC(),
+		Skipped:            n.Skipped,
+	}
+	if n.Err != nil {
+		p.Error = n.Err.Error()
+	}
+	return p
+}
+
+// NotifierFunc adapts an ordinary function to a rotate.Notifier
+type NotifierFunc func(context.Context, rotate.Notification) error
+
+func (f NotifierFunc) Notify(ctx context.Context, n rotate.Notification) error {
+	return f(ctx, n)
+}
+
+// Filter returns a rotate.Notifier that only passes notifications of the given kinds to next
+// e.g. Filter(Slack(c), rotate.StepFailed, rotate.RotationCompleted)
+func Filter(next rotate.Notifier, kinds ...rotate.NotificationKind) rotate.Notifier {
+	allowed := make(map[rotate.NotificationKind]bool, len(kinds))
+	for _, kind := range kinds {
+		allowed[kind] = true
+	}
+	return NotifierFunc(func(ctx context.Context, n rotate.Notification) error {
+		if !allowed[n.Kind] {
+			return nil
+		}
+		return next.Notify(ctx, n)
+	})
+}
+
+// Multi returns a rotate.Notifier that delivers each notification to every notifier, joining their errors
+func Multi(notifiers ...rotate.Notifier) rotate.Notifier {
+	return NotifierFunc(func(ctx context.Context, n rotate.Notification) error {
+		var errs []error
+		for _, notifier := range notifiers {
+			if err := notifier.Notify(ctx, n); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testNotification(kind rotate.NotificationKind) rotate.Notification {
+	return rotate.Notification{
+		Kind:  kind,
+		Event: rotate.Event{SecretId: "my-secret", ClientRequestToken: "version-2", Step: rotate.StepFinish},
+		Time:  time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func testServer(t *testing.T, status int) (*httptest.Server, chan received) {
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestWebhook(t *testing.T) {
+	t.Run("signed payload", func(t *testing.T) {
+		server, requests := testServer(t, http.StatusNoContent)
+		secret := []byte("signing-secret")
+		notifier := Webhook(WebhookConfig{URL: server.URL, Secret: secret, Headers: map[string]string{"X-Team": "security"}})
+
+		assert.NoError(t, notifier.Notify(context.TODO(), testNotification(rotate.RotationCompleted)))
+
+		req := <-requests
+		assert.Equal(t, "security", req.header.Get("X-Team"))
+		assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+		timestamp := req.header.Get(TimestampHeader)
+		assert.NotEmpty(t, timestamp)
+		assert.True(t, hmac.Equal([]byte(Sign(secret, timestamp, req.body)), []byte(req.header.Get(SignatureHeader))))
+		assert.False(t, hmac.Equal([]byte(Sign([]byte("other"), timestamp, req.body)), []byte(req.header.Get(SignatureHeader))))
+
+		var payload Payload
+		assert.NoError(t, json.Unmarshal(req.body, &payload))
+		assert.Equal(t, Payload{
+			Kind:               rotate.RotationCompleted,
+			SecretId:           "my-secret",
+			Step:               rotate.StepFinish,
+			ClientRequestToken: "version-2",
+			Time:               time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC),
+		}, payload)
+	})
+
+	t.Run("unsigned payload includes the error", func(t *testing.T) {
+		server, requests := testServer(t, http.StatusOK)
+		n := testNotification(rotate.StepFailed)
+		n.Err = errors.New("access denied")
+
+		assert.NoError(t, Webhook(WebhookConfig{URL: server.URL}).Notify(context.TODO(), n))
+
+		req := <-requests
+		assert.Empty(t, req.header.Get(SignatureHeader))
+		var payload Payload
+		assert.NoError(t, json.Unmarshal(req.body, &payload))
+		assert.Equal(t, "access denied", payload.Error)
+	})
+
+	t.Run("errors leave out the url", func(t *testing.T) {
+		server, _ := testServer(t, http.StatusForbidden)
+		url := server.URL + "/hooks/T000/B000/token"
+
+		err := Webhook(WebhookConfig{URL: url}).Notify(context.TODO(), testNotification(rotate.StepStarted))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "403")
+			assert.NotContains(t, err.Error(), "token")
+		}
+
+		server.Close()
+		err = Webhook(WebhookConfig{URL: url}).Notify(context.TODO(), testNotification(rotate.StepStarted))
+		if assert.Error(t, err) {
+			assert.NotContains(t, err.Error(), "token")
+		}
+	})
+}
+
+func TestSlack(t *testing.T) {
+	server, requests := testServer(t, http.StatusOK)
+	notifier := Slack(SlackConfig{WebhookURL: server.URL, Channel: "#security"})
+
+	n := testNotification(rotate.StepFailed)
+	n.Err = errors.New("access denied")
+	assert.NoError(t, notifier.Notify(context.TODO(), n))
+
+	var message SlackMessage
+	assert.NoError(t, json.Unmarshal((<-requests).body, &message))
+	assert.Equal(t, "#security", message.Channel)
+	assert.Equal(t, ":x: finishSecret failed for `my-secret`: access denied", message.Text)
+
+	assert.Equal(t, ":lock: Rotated `my-secret` to version `version-2`", SlackText(testNotification(rotate.RotationCompleted)))
+}
+
+func TestSNS(t *testing.T) {
+	api := &localSNS{}
+	notifier := SNS(SNSConfig{SNS: api, TopicArn: "arn:aws:sns:us-east-1:123456789012:rotations"})
+
+	assert.NoError(t, notifier.Notify(context.TODO(), testNotification(rotate.StepSucceeded)))
+	if !assert.Len(t, api.Published, 1) {
+		return
+	}
+
+	input := api.Published[0]
+	assert.Equal(t, "arn:aws:sns:us-east-1:123456789012:rotations", *input.TopicArn)
+	assert.Equal(t, "stepSucceeded", *input.MessageAttributes["kind"].StringValue)
+	assert.Equal(t, "my-secret", *input.MessageAttributes["secretId"].StringValue)
+	assert.Equal(t, "finishSecret", *input.MessageAttributes["step"].StringValue)
+
+	var payload Payload
+	assert.NoError(t, json.Unmarshal([]byte(*input.Message), &payload))
+	assert.Equal(t, rotate.StepSucceeded, payload.Kind)
+}
+
+func TestFilter(t *testing.T) {
+	var kinds []rotate.NotificationKind
+	record := NotifierFunc(func(_ context.Context, n rotate.Notification) error {
+		kinds = append(kinds, n.Kind)
+		return nil
+	})
+
+	notifier := Filter(record, rotate.StepFailed, rotate.RotationCompleted)
+	for _, kind := range []rotate.NotificationKind{rotate.StepStarted, rotate.StepFailed, rotate.StepSucceeded, rotate.RotationCompleted} {
+		assert.NoError(t, notifier.Notify(context.TODO(), testNotification(kind)))
+	}
+	assert.Equal(t, []rotate.NotificationKind{rotate.StepFailed, rotate.RotationCompleted}, kinds)
+}
+
+func TestMulti(t *testing.T) {
+	first, second := errors.New("first"), errors.New("second")
+	calls := 0
+	failing := func(err error) rotate.Notifier {
+		return NotifierFunc(func(context.Context, rotate.Notification) error {
+			calls++
+			return err
+		})
+	}
+
+	err := Multi(failing(first), failing(nil), failing(second)).Notify(context.TODO(), testNotification(rotate.StepStarted))
+	assert.Equal(t, 3, calls)
+	assert.ErrorIs(t, err, first)
+	assert.ErrorIs(t, err, second)
+	assert.NoError(t, Multi(failing(nil)).Notify(context.TODO(), testNotification(rotate.StepStarted)))
+}
+
+type localSNS struct {
+	Published []*sns.PublishInput
+}
+
+func (l *localSNS) Publish(_ context.Context, params *sns.PublishInput, _ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	l.Published = append(l.Published, params)
+	return &sns.PublishOutput{}, nil
+}
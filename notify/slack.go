@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"net/http"
+	"time"
+)
+
+type SlackConfig struct {
+	// WebhookURL is a Slack incoming webhook, or any endpoint accepting a Slack compatible message
+	WebhookURL string
+
+	// Channel, Username and IconEmoji optionally override the defaults of the incoming webhook
+	Channel   string
+	Username  string
+	IconEmoji string
+
+	// Client sends the requests, defaults to a client with a 10 second timeout
+	Client *http.Client
+}
+
+// SlackMessage is the Slack compatible payload posted for each notification
+type SlackMessage struct {
+	Text      string `json:"text"`
+	Channel   string `json:"channel,omitempty"`
+	Username  string `json:"username,omitempty"`
+	IconEmoji string `json:"icon_emoji,omitempty"`
+}
+
+// Slack returns a rotate.Notifier that posts a Slack message for each notification
+// Slack channels are usually only interested in some notifications, see Filter.
+func Slack(c SlackConfig) rotate.Notifier {
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return NotifierFunc(func(ctx context.Context, n rotate.Notification) error {
+		body, err := json.Marshal(SlackMessage{
+			Text:      SlackText(n),
+			Channel:   c.Channel,
+			Username:  c.Username,
+			IconEmoji: c.IconEmoji,
+		})
+		if err != nil {
+			return err
+		}
+		return post(ctx, c.Client, c.WebhookURL, nil, body)
+	})
+}
+
+// SlackText formats a notification as Slack mrkdwn
+func SlackText(n rotate.Notification) string {
+	secret := fmt.Sprintf("`%s`", n.Event.SecretId)
+	switch n.Kind {
+	case rotate.StepStarted:
+		return fmt.Sprintf(":hourglass_flowing_sand: Started %s for %s", n.Event.Step, secret)
+	case rotate.StepSucceeded:
+		if n.Skipped {
+			return fmt.Sprintf(":fast_forward: %s for %s had already completed", n.Event.Step, secret)
+		}
+		return fmt.Sprintf(":white_check_mark: Completed %s for %s", n.Event.Step, secret)
+	case rotate.StepFailed:
+		return fmt.Sprintf(":x: %s failed for %s: %s", n.Event.Step, secret, n.Err)
+	case rotate.RotationCompleted:
+		return fmt.Sprintf(":lock: Rotated %s to version `%s`", secret, n.Event.ClientRequestToken)
+	}
+	return fmt.Sprintf("%s %s for %s", n.Kind, n.Event.Step, secret)
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+)
+
+// SNSApi is the subset of the SNS client used to publish notifications
+type SNSApi interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+type SNSConfig struct {
+	SNS SNSApi
+
+	// TopicArn receives the JSON Payload of every notification
+	TopicArn string
+}
+
+// SNS returns a rotate.Notifier that publishes the JSON Payload of each notification to a topic
+// The kind, secret id and step are also set as message attributes so that subscriptions can filter on them.
+func SNS(c SNSConfig) rotate.Notifier {
+	return NotifierFunc(func(ctx context.Context, n rotate.Notification) error {
+		payload := NewPayload(n)
+		message, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		body := string(message)
+		_, err = c.SNS.Publish(ctx, &sns.PublishInput{
+			TopicArn: &c.TopicArn,
+			Message:  &body,
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"kind":     stringAttribute(string(payload.Kind)),
+				"secretId": stringAttribute(payload.SecretId),
+				"step":     stringAttribute(string(payload.Step)),
+			},
+		})
+		return err
+	})
+}
+
+func stringAttribute(value string) types.MessageAttributeValue {
+	dataType := "String"
+	return types.MessageAttributeValue{DataType: &dataType, StringValue: &value}
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 signature of a webhook request, formatted as sha256=<hex>
+	SignatureHeader = "X-Rotate-Signature"
+
+	// TimestampHeader carries the unix time the request was signed at, which is included in the signature
+	TimestampHeader = "X-Rotate-Timestamp"
+)
+
+type WebhookConfig struct {
+	// URL receives a POST of the JSON Payload for every notification
+	URL string
+
+	// Secret signs each request when set, see Sign
+	Secret []byte
+
+	// Headers are added to every request
+	Headers map[string]string
+
+	// Client sends the requests, defaults to a client with a 10 second timeout
+	Client *http.Client
+}
+
+// Webhook returns a rotate.Notifier that posts the JSON Payload of each notification to a URL
+func Webhook(c WebhookConfig) rotate.Notifier {
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return NotifierFunc(func(ctx context.Context, n rotate.Notification) error {
+		body, err := json.Marshal(NewPayload(n))
+		if err != nil {
+			return err
+		}
+
+		headers := make(map[string]string, len(c.Headers)+2)
+		for k, v := range c.Headers {
+			headers[k] = v
+		}
+		if len(c.Secret) > 0 {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			headers[TimestampHeader] = timestamp
+			headers[SignatureHeader] = Sign(c.Secret, timestamp, body)
+		}
+		return post(ctx, c.Client, c.URL, headers, body)
+	})
+}
+
+// Sign returns the signature of a webhook request, the HMAC-SHA256 of the timestamp, a period, then the body
+// Receivers should recompute the signature with the TimestampHeader value and compare it using hmac.Equal, rejecting
+// timestamps that are too old to prevent replays.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func post(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("notify: %w", withoutURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notify: webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// withoutURL removes the URL from err, as webhook URLs often embed a token
+func withoutURL(err error) error {
+	var urlErr *neturl.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
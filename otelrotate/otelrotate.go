@@ -0,0 +1,82 @@
+// Package otelrotate instruments a rotation with OpenTelemetry tracing
+// Each Handle call is recorded as a span, with child spans for every Secrets Manager API call and Service hook.
+package otelrotate
+
+import (
+	"context"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/printerlogic/go-secretsmanager-rotate/otelrotate"
+
+// Attribute keys recorded on spans
+const (
+	SecretIdKey            = attribute.Key("secretsmanager.secret_id")
+	StepKey                = attribute.Key("rotate.step")
+	TokenKey               = attribute.Key("rotate.client_request_token")
+	VersionIdKey           = attribute.Key("secretsmanager.version_id")
+	VersionStageKey        = attribute.Key("secretsmanager.version_stage")
+	MoveToVersionIdKey     = attribute.Key("secretsmanager.move_to_version_id")
+	RemoveFromVersionIdKey = attribute.Key("secretsmanager.remove_from_version_id")
+)
+
+// Instrument returns a copy of c that traces each step, Service hook and Secrets Manager API call
+// provider defaults to the global TracerProvider when nil.
+func Instrument(c rotate.Config, provider trace.TracerProvider) rotate.Config {
+	c.SecretsManager = SecretsManager(c.SecretsManager, provider)
+	c.Middleware = append([]rotate.Middleware{Middleware(provider)}, c.Middleware...)
+	c.HookMiddleware = append([]rotate.HookMiddleware{HookMiddleware(provider)}, c.HookMiddleware...)
+	return c
+}
+
+// Middleware returns a rotate.Middleware that records a span for each Handle call
+func Middleware(provider trace.TracerProvider) rotate.Middleware {
+	tracer := tracer(provider)
+	return func(next rotate.Handler) rotate.Handler {
+		return rotate.HandlerFunc(func(ctx context.Context, event rotate.Event) error {
+			ctx, span := tracer.Start(ctx, "rotate "+string(event.Step), trace.WithAttributes(eventAttributes(event)...))
+			defer span.End()
+			return end(span, next.Handle(ctx, event))
+		})
+	}
+}
+
+// HookMiddleware returns a rotate.HookMiddleware that records a span for each call into the Service
+func HookMiddleware(provider trace.TracerProvider) rotate.HookMiddleware {
+	tracer := tracer(provider)
+	return func(event rotate.Event, name string, next rotate.Hook) rotate.Hook {
+		return func(ctx context.Context) error {
+			ctx, span := tracer.Start(ctx, "rotate.Service "+name, trace.WithAttributes(eventAttributes(event)...))
+			defer span.End()
+			return end(span, next(ctx))
+		}
+	}
+}
+
+func tracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(instrumentationName)
+}
+
+func eventAttributes(event rotate.Event) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		SecretIdKey.String(event.SecretId),
+		StepKey.String(string(event.Step)),
+		TokenKey.String(event.ClientRequestToken),
+	}
+}
+
+// end records err on span, returning it unchanged
+func end(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
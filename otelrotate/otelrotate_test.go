@@ -0,0 +1,244 @@
+package otelrotate
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	t.Run("finish step", func(t *testing.T) {
+		exporter, provider := testProvider()
+		sm := &localSecretsManager{versions: map[string]string{
+			rotate.AWSCURRENT: "version-1",
+			rotate.AWSPENDING: "version-2",
+		}}
+		handler := rotate.New(Instrument(rotate.Config{SecretsManager: sm, Service: &finishingService{}}, provider))
+
+		event := rotate.Event{SecretId: "my-secret", ClientRequestToken: "version-2", Step: rotate.StepFinish}
+		assert.NoError(t, handler.Handle(context.TODO(), event))
+
+		spans := exporter.GetSpans()
+		names := make([]string, len(spans))
+		for i, span := range spans {
+			names[i] = span.Name
+		}
+		// spans are exported as they end, so children precede their parent
+		assert.Equal(t, []string{
+			"SecretsManager.GetSecretValue",
+			"SecretsManager.GetSecretValue",
+			"rotate.Service Finish",
+			"SecretsManager.UpdateSecretVersionStage",
+			"rotate finishSecret",
+		}, names)
+
+		root := spans[len(spans)-1]
+		assert.False(t, root.Parent.IsValid())
+		for _, span := range spans[:len(spans)-1] {
+			assert.Equal(t, root.SpanContext.SpanID(), span.Parent.SpanID(), span.Name)
+			assert.Equal(t, root.SpanContext.TraceID(), span.SpanContext.TraceID(), span.Name)
+		}
+
+		assertAttributes(t, root, SecretIdKey.String("my-secret"), StepKey.String("finishSecret"), TokenKey.String("version-2"))
+		assertAttributes(t, spans[2], SecretIdKey.String("my-secret"), StepKey.String("finishSecret"), TokenKey.String("version-2"))
+		assertAttributes(t, spans[0], SecretIdKey.String("my-secret"), VersionStageKey.String(rotate.AWSCURRENT), VersionIdKey.String("version-1"))
+		assertAttributes(t, spans[1], VersionStageKey.String(rotate.AWSPENDING), VersionIdKey.String("version-2"))
+		assertAttributes(t, spans[3],
+			SecretIdKey.String("my-secret"),
+			VersionStageKey.String(rotate.AWSCURRENT),
+			MoveToVersionIdKey.String("version-2"),
+			RemoveFromVersionIdKey.String("version-1"),
+		)
+	})
+
+	t.Run("create step", func(t *testing.T) {
+		exporter, provider := testProvider()
+		sm := &localSecretsManager{versions: map[string]string{rotate.AWSCURRENT: "version-1"}}
+		handler := rotate.New(Instrument(rotate.Config{SecretsManager: sm, Service: &finishingService{}}, provider))
+
+		event := rotate.Event{SecretId: "my-secret", ClientRequestToken: "version-2", Step: rotate.StepCreate}
+		assert.NoError(t, handler.Handle(context.TODO(), event))
+
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 5) {
+			// create first checks for a stale AWSPENDING version
+			assert.Equal(t, "SecretsManager.DescribeSecret", spans[1].Name)
+			for _, span := range spans {
+				assert.NotEqual(t, codes.Error, span.Status.Code, span.Name)
+			}
+			assert.Equal(t, "rotate.Service Create", spans[2].Name)
+			assert.Equal(t, "SecretsManager.PutSecretValue", spans[3].Name)
+			assertAttributes(t, spans[3],
+				SecretIdKey.String("my-secret"),
+				VersionIdKey.String("version-2"),
+				VersionStageKey.StringSlice([]string{rotate.AWSPENDING}),
+			)
+		}
+	})
+
+	t.Run("spans are named after the Service method called", func(t *testing.T) {
+		exporter, provider := testProvider()
+		sm := &localSecretsManager{versions: map[string]string{
+			rotate.AWSCURRENT: "version-1",
+			rotate.AWSPENDING: "orphaned",
+		}}
+		handler := rotate.New(Instrument(rotate.Config{SecretsManager: sm, Service: &cleaningService{}}, provider))
+
+		event := rotate.Event{SecretId: "my-secret", ClientRequestToken: "version-2", Step: rotate.StepCreate}
+		if !assert.NoError(t, handler.Handle(context.TODO(), event)) {
+			return
+		}
+
+		var names []string
+		for _, span := range exporter.GetSpans() {
+			names = append(names, span.Name)
+		}
+		assert.Subset(t, names, []string{"rotate.Service Clean", "rotate.Service Create"})
+	})
+
+	t.Run("routes by tag through the instrumented client", func(t *testing.T) {
+		exporter, provider := testProvider()
+		sm := &taggedSecretsManager{
+			localSecretsManager: &localSecretsManager{versions: map[string]string{
+				rotate.AWSCURRENT: "version-1",
+				rotate.AWSPENDING: "version-2",
+			}},
+			tags: map[string]string{"rotation": "finishing"},
+		}
+		handler := rotate.NewRouter(rotate.RouterConfig{
+			Config: Instrument(rotate.Config{SecretsManager: sm}, provider),
+			Routes: []rotate.Route{{Match: rotate.Tag("rotation", "finishing"), Service: &finishingService{}}},
+		})
+
+		event := rotate.Event{SecretId: "my-secret", ClientRequestToken: "version-2", Step: rotate.StepFinish}
+		if !assert.NoError(t, handler.Handle(context.TODO(), event)) {
+			return
+		}
+
+		spans := exporter.GetSpans()
+		if assert.NotEmpty(t, spans) {
+			assert.Equal(t, "SecretsManager.DescribeSecret", spans[0].Name)
+			assertAttributes(t, spans[0], SecretIdKey.String("my-secret"))
+		}
+	})
+
+	t.Run("errors are recorded", func(t *testing.T) {
+		exporter, provider := testProvider()
+		failure := errors.New("access denied")
+		sm := &localSecretsManager{err: failure}
+		handler := rotate.New(Instrument(rotate.Config{SecretsManager: sm, Service: &finishingService{}}, provider))
+
+		event := rotate.Event{SecretId: "my-secret", ClientRequestToken: "version-2", Step: rotate.StepCreate}
+		assert.ErrorIs(t, handler.Handle(context.TODO(), event), failure)
+
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 2) {
+			for _, span := range spans {
+				assert.Equal(t, codes.Error, span.Status.Code, span.Name)
+				assert.Equal(t, "access denied", span.Status.Description, span.Name)
+				if assert.Len(t, span.Events, 1) {
+					assert.Equal(t, "exception", span.Events[0].Name)
+				}
+			}
+		}
+	})
+}
+
+func testProvider() (*tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	exporter := tracetest.NewInMemoryExporter()
+	return exporter, sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+}
+
+func assertAttributes(t *testing.T, span tracetest.SpanStub, expected ...attribute.KeyValue) {
+	t.Helper()
+	for _, kv := range expected {
+		assert.Contains(t, span.Attributes, kv, span.Name)
+	}
+}
+
+type finishingService struct{}
+
+func (f *finishingService) Create(context.Context, rotate.Secret) (rotate.Secret, error) {
+	return rotate.StringSecret("new"), nil
+}
+
+func (f *finishingService) Finish(context.Context, rotate.Secret) error {
+	return nil
+}
+
+// cleaningService also cleans up after orphaned versions
+type cleaningService struct {
+	finishingService
+}
+
+func (c *cleaningService) Clean(context.Context, rotate.Secret) error {
+	return nil
+}
+
+// localSecretsManager serves a fixed version for each stage
+type localSecretsManager struct {
+	versions map[string]string
+	err      error
+}
+
+func (l *localSecretsManager) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	if params.VersionId != nil {
+		return &secretsmanager.GetSecretValueOutput{VersionId: params.VersionId, SecretString: aws.String("value")}, nil
+	}
+	versionId, ok := l.versions[*params.VersionStage]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("no version labelled " + *params.VersionStage)}
+	}
+	return &secretsmanager.GetSecretValueOutput{
+		VersionId:    aws.String(versionId),
+		SecretString: aws.String("value"),
+	}, nil
+}
+
+func (l *localSecretsManager) DescribeSecret(_ context.Context, params *secretsmanager.DescribeSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	output := &secretsmanager.DescribeSecretOutput{ARN: params.SecretId, VersionIdsToStages: map[string][]string{}}
+	for stage, versionId := range l.versions {
+		output.VersionIdsToStages[versionId] = append(output.VersionIdsToStages[versionId], stage)
+	}
+	return output, nil
+}
+
+func (l *localSecretsManager) PutSecretValue(context.Context, *secretsmanager.PutSecretValueInput, ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	return &secretsmanager.PutSecretValueOutput{}, l.err
+}
+
+func (l *localSecretsManager) UpdateSecretVersionStage(context.Context, *secretsmanager.UpdateSecretVersionStageInput, ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	return &secretsmanager.UpdateSecretVersionStageOutput{}, l.err
+}
+
+// taggedSecretsManager also tags every secret with tags
+type taggedSecretsManager struct {
+	*localSecretsManager
+	tags map[string]string
+}
+
+func (d *taggedSecretsManager) DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	output, err := d.localSecretsManager.DescribeSecret(ctx, params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range d.tags {
+		output.Tags = append(output.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return output, nil
+}
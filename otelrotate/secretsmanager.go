@@ -0,0 +1,107 @@
+package otelrotate
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SecretsManager returns a rotate.SecretsManagerApi that records a client span for each call made to api
+// When api implements rotate.SecretDescriber so does the returned client, keeping routing by tag and the discovery of
+// replica regions available.
+func SecretsManager(api rotate.SecretsManagerApi, provider trace.TracerProvider) rotate.SecretsManagerApi {
+	s := &secretsManager{api: api, tracer: tracer(provider)}
+	if _, ok := api.(rotate.SecretDescriber); ok {
+		return &describingSecretsManager{s}
+	}
+	return s
+}
+
+type secretsManager struct {
+	api    rotate.SecretsManagerApi
+	tracer trace.Tracer
+}
+
+func (s *secretsManager) start(ctx context.Context, operation string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "SecretsManager."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rpc.system", "aws-api"), attribute.String("rpc.service", "SecretsManager"), attribute.String("rpc.method", operation)),
+		trace.WithAttributes(attributes...),
+	)
+}
+
+func (s *secretsManager) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	ctx, span := s.start(ctx, "GetSecretValue", optional(
+		SecretIdKey, params.SecretId,
+		VersionStageKey, params.VersionStage,
+		VersionIdKey, params.VersionId,
+	)...)
+	defer span.End()
+
+	output, err := s.api.GetSecretValue(ctx, params, optFns...)
+	if err == nil && output != nil && params.VersionId == nil {
+		span.SetAttributes(optional(VersionIdKey, output.VersionId)...)
+	}
+	return output, end(span, err)
+}
+
+func (s *secretsManager) PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	attributes := optional(
+		SecretIdKey, params.SecretId,
+		VersionIdKey, params.ClientRequestToken,
+	)
+	ctx, span := s.start(ctx, "PutSecretValue", append(attributes, VersionStageKey.StringSlice(params.VersionStages))...)
+	defer span.End()
+
+	output, err := s.api.PutSecretValue(ctx, params, optFns...)
+	return output, end(span, err)
+}
+
+func (s *secretsManager) UpdateSecretVersionStage(ctx context.Context, params *secretsmanager.UpdateSecretVersionStageInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	ctx, span := s.start(ctx, "UpdateSecretVersionStage", optional(
+		SecretIdKey, params.SecretId,
+		VersionStageKey, params.VersionStage,
+		MoveToVersionIdKey, params.MoveToVersionId,
+		RemoveFromVersionIdKey, params.RemoveFromVersionId,
+	)...)
+	defer span.End()
+
+	output, err := s.api.UpdateSecretVersionStage(ctx, params, optFns...)
+	return output, end(span, err)
+}
+
+// describingSecretsManager also forwards DescribeSecret to a client that implements it
+type describingSecretsManager struct {
+	*secretsManager
+}
+
+func (d *describingSecretsManager) DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	ctx, span := d.start(ctx, "DescribeSecret", optional(SecretIdKey, params.SecretId)...)
+	defer span.End()
+
+	output, err := d.api.(rotate.SecretDescriber).DescribeSecret(ctx, params, optFns...)
+	return output, end(span, err)
+}
+
+// optional converts pairs of attribute.Key and *string into attributes, skipping nil values
+func optional(pairs ...interface{}) []attribute.KeyValue {
+	attributes := make([]attribute.KeyValue, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if value := pairs[i+1].(*string); value != nil {
+			attributes = append(attributes, pairs[i].(attribute.Key).String(*value))
+		}
+	}
+	return attributes
+}
+
+// Ensure that the wrapper remains SecretsManagerApi compatible
+func _(s *secretsManager) rotate.SecretsManagerApi {
+	return s
+}
+
+// Ensure that the describing wrapper remains SecretDescriber compatible
+func _(d *describingSecretsManager) rotate.SecretDescriber {
+	return d
+}
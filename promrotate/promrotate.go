@@ -0,0 +1,136 @@
+// Package promrotate exposes rotation metrics to Prometheus, for rotations hosted by a long-running service
+package promrotate
+
+import (
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Namespace prefixes every metric name, defaults to "secretsmanager_rotation"
+	Namespace string
+
+	// HookBuckets are the histogram buckets for Service hook latency in seconds, defaults to prometheus.DefBuckets
+	HookBuckets []float64
+
+	// APIBuckets are the histogram buckets for Secrets Manager API latency in seconds, defaults to prometheus.DefBuckets
+	APIBuckets []float64
+
+	// Now is used to calculate the time since the last successful finish, defaults to time.Now
+	Now func() time.Time
+}
+
+// Collector is a prometheus.Collector that records the rotate.Metrics of a rotator
+type Collector struct {
+	steps        *prometheus.CounterVec
+	hooks        *prometheus.HistogramVec
+	apiCalls     *prometheus.HistogramVec
+	sinceSuccess *prometheus.Desc
+	now          func() time.Time
+
+	mu       sync.Mutex
+	finished map[string]time.Time
+}
+
+// New returns a Collector, it must be registered with a prometheus.Registerer or served through Handler
+func New(c Config) *Collector {
+	if c.Namespace == "" {
+		c.Namespace = "secretsmanager_rotation"
+	}
+	if c.HookBuckets == nil {
+		c.HookBuckets = prometheus.DefBuckets
+	}
+	if c.APIBuckets == nil {
+		c.APIBuckets = prometheus.DefBuckets
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+
+	return &Collector{
+		steps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: c.Namespace,
+			Name:      "steps_total",
+			Help:      "Rotation steps handled, by step and outcome.",
+		}, []string{"step", "outcome"}),
+		hooks: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.Namespace,
+			Name:      "hook_duration_seconds",
+			Help:      "Latency of calls into the rotation Service, by step and outcome.",
+			Buckets:   c.HookBuckets,
+		}, []string{"step", "outcome"}),
+		apiCalls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.Namespace,
+			Name:      "api_call_duration_seconds",
+			Help:      "Latency of Secrets Manager API calls, by operation and outcome.",
+			Buckets:   c.APIBuckets,
+		}, []string{"operation", "outcome"}),
+		sinceSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(c.Namespace, "", "seconds_since_last_success"),
+			"Seconds since the last successful finish step, by secret.",
+			[]string{"secret_id"}, nil,
+		),
+		now:      c.Now,
+		finished: make(map[string]time.Time),
+	}
+}
+
+func (c *Collector) StepCompleted(event rotate.Event, outcome rotate.Outcome, _ time.Duration) {
+	c.steps.WithLabelValues(string(event.Step), string(outcome)).Inc()
+	if event.Step == rotate.StepFinish && outcome == rotate.OutcomeSuccess {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.finished[event.SecretId] = c.now()
+	}
+}
+
+func (c *Collector) HookCompleted(event rotate.Event, err error, duration time.Duration) {
+	c.hooks.WithLabelValues(string(event.Step), outcome(err)).Observe(duration.Seconds())
+}
+
+func (c *Collector) APICallCompleted(_ string, operation string, err error, duration time.Duration) {
+	c.apiCalls.WithLabelValues(operation, outcome(err)).Observe(duration.Seconds())
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.steps.Describe(ch)
+	c.hooks.Describe(ch)
+	c.apiCalls.Describe(ch)
+	ch <- c.sinceSuccess
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.steps.Collect(ch)
+	c.hooks.Collect(ch)
+	c.apiCalls.Collect(ch)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for secretId, finished := range c.finished {
+		ch <- prometheus.MustNewConstMetric(c.sinceSuccess, prometheus.GaugeValue, now.Sub(finished).Seconds(), secretId)
+	}
+}
+
+// Handler returns an http.Handler serving only the metrics of c
+func (c *Collector) Handler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// Ensure that Collector remains compatible with both rotate.Metrics and prometheus.Collector
+func _(c *Collector) (rotate.Metrics, prometheus.Collector) {
+	return c, c
+}
@@ -0,0 +1,77 @@
+package promrotate
+
+import (
+	"errors"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := New(Config{Now: func() time.Time { return now }})
+
+	event := func(step rotate.Step) rotate.Event {
+		return rotate.Event{SecretId: "my-secret", ClientRequestToken: "version-2", Step: step}
+	}
+
+	c.StepCompleted(event(rotate.StepCreate), rotate.OutcomeSuccess, time.Millisecond)
+	c.StepCompleted(event(rotate.StepCreate), rotate.OutcomeSkipped, time.Millisecond)
+	c.StepCompleted(event(rotate.StepTest), rotate.OutcomeFailure, time.Millisecond)
+	c.HookCompleted(event(rotate.StepTest), errors.New("rejected"), 20*time.Millisecond)
+	c.HookCompleted(event(rotate.StepCreate), nil, 10*time.Millisecond)
+	c.APICallCompleted("my-secret", "GetSecretValue", nil, 5*time.Millisecond)
+	c.StepCompleted(event(rotate.StepFinish), rotate.OutcomeSuccess, time.Millisecond)
+	// a finish that had already happened does not reset the gauge
+	now = now.Add(90 * time.Second)
+	c.StepCompleted(event(rotate.StepFinish), rotate.OutcomeSkipped, time.Millisecond)
+
+	t.Run("counters", func(t *testing.T) {
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.steps.WithLabelValues("createSecret", "success")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.steps.WithLabelValues("createSecret", "skipped")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.steps.WithLabelValues("testSecret", "failure")))
+	})
+
+	t.Run("lint", func(t *testing.T) {
+		problems, err := testutil.CollectAndLint(c)
+		assert.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("registry", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		assert.NoError(t, registry.Register(c))
+
+		expected := `
+# HELP secretsmanager_rotation_seconds_since_last_success Seconds since the last successful finish step, by secret.
+# TYPE secretsmanager_rotation_seconds_since_last_success gauge
+secretsmanager_rotation_seconds_since_last_success{secret_id="my-secret"} 90
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "secretsmanager_rotation_seconds_since_last_success"))
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		server := httptest.NewServer(c.Handler())
+		defer server.Close()
+
+		resp, err := server.Client().Get(server.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+
+		text := string(body)
+		assert.Contains(t, text, `secretsmanager_rotation_steps_total{outcome="success",step="finishSecret"} 1`)
+		assert.Contains(t, text, `secretsmanager_rotation_hook_duration_seconds_count{outcome="error",step="testSecret"} 1`)
+		assert.Contains(t, text, `secretsmanager_rotation_api_call_duration_seconds_bucket{operation="GetSecretValue",outcome="ok",le="0.005"} 1`)
+		assert.Contains(t, text, `secretsmanager_rotation_seconds_since_last_success{secret_id="my-secret"} 90`)
+	})
+}
@@ -0,0 +1,56 @@
+package rotate
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// redacted is rendered in place of secret material
+const redacted = "[REDACTED]"
+
+// Redacted can be embedded in a Secret so that it renders as redacted through fmt, %#v and log/slog
+// This guards against secret material leaking through an accidental log statement or error wrap.
+// encoding/json ignores the embedded field, YAML structs should tag it with `yaml:"-"`.
+type Redacted struct{}
+
+func (Redacted) Format(f fmt.State, _ rune) {
+	_, _ = io.WriteString(f, redacted)
+}
+
+func (Redacted) GoString() string {
+	return redacted
+}
+
+func (Redacted) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// Ensure that Redacted remains compatible with each of the formatting interfaces
+func _(r Redacted) (fmt.Formatter, fmt.GoStringer, slog.LogValuer) {
+	return r, r, r
+}
+
+func (s StringSecret) Format(f fmt.State, verb rune) {
+	Redacted{}.Format(f, verb)
+}
+
+func (s StringSecret) GoString() string {
+	return Redacted{}.GoString()
+}
+
+func (s StringSecret) LogValue() slog.Value {
+	return Redacted{}.LogValue()
+}
+
+func (b BinarySecret) Format(f fmt.State, verb rune) {
+	Redacted{}.Format(f, verb)
+}
+
+func (b BinarySecret) GoString() string {
+	return Redacted{}.GoString()
+}
+
+func (b BinarySecret) LogValue() slog.Value {
+	return Redacted{}.LogValue()
+}
@@ -0,0 +1,126 @@
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const testSecretValue = "hunter2-top-secret"
+
+func TestRedaction(t *testing.T) {
+	secrets := map[string]Secret{
+		"string": StringSecret(testSecretValue),
+		"binary": BinarySecret(testSecretValue),
+		"struct": &redactedStruct{Password: testSecretValue},
+	}
+
+	for name, secret := range secrets {
+		t.Run(name, func(t *testing.T) {
+			t.Run("fmt verbs", func(t *testing.T) {
+				for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d"} {
+					out := fmt.Sprintf(format, secret)
+					assert.NotContains(t, out, testSecretValue, format)
+					assert.NotContains(t, out, fmt.Sprintf("%x", testSecretValue), format)
+					assert.Contains(t, out, redacted, format)
+				}
+				assert.Equal(t, redacted, fmt.Sprint(secret))
+			})
+
+			t.Run("wrapped errors", func(t *testing.T) {
+				err := fmt.Errorf("rotation failed: %w", fmt.Errorf("bad secret %v", secret))
+				assert.NotContains(t, err.Error(), testSecretValue)
+			})
+
+			t.Run("slog", func(t *testing.T) {
+				var buf bytes.Buffer
+				slog.New(slog.NewTextHandler(&buf, nil)).Info("rotating", "secret", secret)
+				slog.New(slog.NewJSONHandler(&buf, nil)).Info("rotating", "secret", secret)
+				assert.NotContains(t, buf.String(), testSecretValue)
+				assert.Contains(t, buf.String(), redacted)
+			})
+
+			t.Run("value is unchanged", func(t *testing.T) {
+				value, err := secret.Value()
+				assert.NoError(t, err)
+				assert.Contains(t, string(value), testSecretValue)
+			})
+		})
+	}
+
+	t.Run("rotation logs", func(t *testing.T) {
+		var logOutput bytes.Buffer
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: aws.String(testSecretValue + "-current")},
+			},
+		}
+		svc := &leakyService{logger: log.New(&logOutput, "", 0), OnCreate: StringSecret(testSecretValue + "-pending")}
+		r := &rotator{
+			api:            sm,
+			service:        svc,
+			logger:         log.New(&logOutput, "", 0),
+			networkTimeout: time.Second,
+		}
+
+		err := r.Handle(context.TODO(), testEvent(StepCreate))
+		assert.NoError(t, err)
+
+		sm.Existing[AWSPENDING] = &secretsmanager.GetSecretValueOutput{
+			VersionId:    sm.Creations[0].ClientRequestToken,
+			SecretString: sm.Creations[0].SecretString,
+		}
+		svc.Fail = true
+		event := testEvent(StepTest)
+		event.ClientRequestToken = *sm.Creations[0].ClientRequestToken
+		err = r.Handle(context.TODO(), event)
+		if assert.Error(t, err) {
+			assert.NotContains(t, err.Error(), testSecretValue)
+		}
+
+		assert.NotEmpty(t, logOutput.String())
+		assert.NotContains(t, logOutput.String(), testSecretValue)
+	})
+}
+
+type redactedStruct struct {
+	Redacted
+	Password string
+}
+
+func (s *redactedStruct) Binary() bool {
+	return false
+}
+
+func (s *redactedStruct) Value() ([]byte, error) {
+	return []byte(s.Password), nil
+}
+
+// leakyService carelessly logs and wraps the secrets it is given
+type leakyService struct {
+	logger   *log.Logger
+	OnCreate Secret
+	Fail     bool
+}
+
+func (l *leakyService) Create(_ context.Context, current Secret) (Secret, error) {
+	l.logger.Printf("creating from %v", current)
+	l.logger.Printf("created %#v", l.OnCreate)
+	return l.OnCreate, nil
+}
+
+func (l *leakyService) Test(_ context.Context, pending Secret) error {
+	l.logger.Printf("testing %s", pending)
+	if l.Fail {
+		return fmt.Errorf("rejected %q: %w", pending, errors.New("access denied"))
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ReplicaConfig struct {
+	// Regions are the regions the secret is replicated to, when empty they are discovered with the Describer
+	Regions []string
+
+	// Describer looks up the replica regions of the secret when Regions is empty, defaults to the SecretsManager client
+	// when it implements SecretDescriber
+	Describer SecretDescriber
+
+	// Client is required and returns the client for a replica region, it is called once for each region
+	Client func(region string) SecretsManagerApi
+
+	// Interval is how long to wait between checks of a replica, defaults to 2 seconds
+	Interval time.Duration
+
+	// Timeout is how long a version may take to reach every replica, defaults to 30 seconds
+	Timeout time.Duration
+}
+
+// ReplicaLagError reports the replica regions where a staging label had not reached a version before the timeout
+type ReplicaLagError struct {
+	Stage     string
+	VersionId string
+	Regions   []string
+
+	// Err is the last error encountered checking a replica, if any
+	Err error
+}
+
+func (e *ReplicaLagError) Error() string {
+	msg := fmt.Sprintf("%s is not attached to version %s in replica regions %s", e.Stage, e.VersionId, strings.Join(e.Regions, ", "))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ReplicaLagError) Unwrap() error {
+	return e.Err
+}
+
+// replicas waits for versions to reach the replica regions of a secret
+type replicas struct {
+	ReplicaConfig
+
+	mu      sync.Mutex
+	clients map[string]SecretsManagerApi
+
+	// err reports a ReplicaConfig that cannot be used, it is returned whenever the replicas are checked
+	err error
+}
+
+func newReplicas(c *ReplicaConfig, api SecretsManagerApi) *replicas {
+	if c == nil {
+		return nil
+	}
+	r := &replicas{ReplicaConfig: *c, clients: make(map[string]SecretsManagerApi)}
+	if r.Client == nil {
+		r.err = errors.New("replica checks require a Client for the replica regions")
+	}
+	if r.Describer == nil {
+		r.Describer, _ = api.(SecretDescriber)
+	}
+	if r.Interval <= 0 {
+		r.Interval = 2 * time.Second
+	}
+	if r.Timeout <= 0 {
+		r.Timeout = 30 * time.Second
+	}
+	return r
+}
+
+func (r *replicas) client(region string) SecretsManagerApi {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[region]
+	if !ok {
+		client = r.Client(region)
+		r.clients[region] = client
+	}
+	return client
+}
+
+// awaitReplicas waits until stage is attached to the version of the event in every replica region, it does nothing
+// when replica checks are not configured
+func (r *rotator) awaitReplicas(ctx context.Context, event Event, stage string) error {
+	if r.replicas == nil {
+		return nil
+	}
+	if r.replicas.err != nil {
+		return r.replicas.err
+	}
+
+	regions, err := r.replicaRegions(ctx, event.SecretId)
+	if err != nil {
+		return fmt.Errorf("finding replica regions: %w", err)
+	}
+	if len(regions) == 0 {
+		return nil
+	}
+	r.logger.Printf("Waiting for %s to reach version %s in replica regions: %s", stage, event.ClientRequestToken, strings.Join(regions, ", "))
+
+	deadline := time.NewTimer(r.replicas.Timeout)
+	defer deadline.Stop()
+	var lastErr error
+	for {
+		var waiting []string
+		for _, region := range regions {
+			reached, err := r.replicaReached(ctx, region, event, stage)
+			if err != nil {
+				lastErr = err
+			}
+			if !reached {
+				waiting = append(waiting, region)
+			}
+		}
+		if len(waiting) == 0 {
+			r.logger.Printf("%s has reached version %s in every replica region", stage, event.ClientRequestToken)
+			return nil
+		}
+		regions = waiting
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return &ReplicaLagError{Stage: stage, VersionId: event.ClientRequestToken, Regions: waiting, Err: lastErr}
+		case <-time.After(r.replicas.Interval):
+		}
+	}
+}
+
+// replicaReached reports whether stage is attached to the version of the event in region
+func (r *rotator) replicaReached(ctx context.Context, region string, event Event, stage string) (bool, error) {
+	ctx, cancel := r.network(ctx)
+	defer cancel()
+
+	secretId := replicaSecretId(event.SecretId, region)
+	start := time.Now()
+	output, err := r.replicas.client(region).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     &secretId,
+		VersionStage: &stage,
+	})
+	r.apiCallCompleted(secretId, "GetSecretValue", err, start)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", region, err)
+	}
+	hold(ctx, OutputAsSecret(output))
+	return output.VersionId != nil && *output.VersionId == event.ClientRequestToken, nil
+}
+
+// replicaRegions returns the configured replica regions, or discovers them with DescribeSecret
+func (r *rotator) replicaRegions(ctx context.Context, secretId string) ([]string, error) {
+	if len(r.replicas.Regions) > 0 {
+		return r.replicas.Regions, nil
+	}
+	if r.replicas.Describer == nil {
+		return nil, fmt.Errorf("no replica Regions are configured and there is no Describer to discover them")
+	}
+
+	ctx, cancel := r.network(ctx)
+	defer cancel()
+	start := time.Now()
+	output, err := r.replicas.Describer.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &secretId})
+	r.apiCallCompleted(secretId, "DescribeSecret", err, start)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []string
+	for _, status := range output.ReplicationStatus {
+		if status.Region != nil {
+			regions = append(regions, *status.Region)
+		}
+	}
+	return regions, nil
+}
+
+// replicaSecretId returns the SecretId of a replica in region, replacing the region of an ARN
+// Secret names are the same in every region.
+func replicaSecretId(secretId, region string) string {
+	parts := strings.SplitN(secretId, ":", 5)
+	if len(parts) < 5 || parts[0] != "arn" {
+		return secretId
+	}
+	parts[3] = region
+	return strings.Join(parts, ":")
+}
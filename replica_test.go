@@ -0,0 +1,181 @@
+package rotate
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const testReplicatedArn = "arn:aws:secretsmanager:us-east-1:123456789012:secret:replicated-AbC123"
+
+func TestReplicas(t *testing.T) {
+	// newReplicated returns a rotator for a secret whose replicas see the primary after lag lookups
+	newReplicated := func(t *testing.T, service Service, lag map[string]int) (*rotator, *fakeSecretsManager, map[string]*laggingReplica) {
+		primary := newFakeSecretsManager(map[string]string{testReplicatedArn: "v0"})
+		replicas := map[string]*laggingReplica{}
+		var regions []string
+		for region, lookups := range lag {
+			regions = append(regions, region)
+			replicas[region] = &laggingReplica{primary: primary, region: region, lag: lookups, fakeSecretsManager: newFakeSecretsManager(nil)}
+			replicas[region].sync()
+		}
+
+		r := testRotator(t, primary, service).(*rotator)
+		r.replicas = newReplicas(&ReplicaConfig{
+			Regions:  regions,
+			Client:   func(region string) SecretsManagerApi { return replicas[region] },
+			Interval: time.Millisecond,
+			Timeout:  100 * time.Millisecond,
+		}, r.api)
+		return r, primary, replicas
+	}
+	pending := func(t *testing.T, r *rotator, primary *fakeSecretsManager) Event {
+		event := Event{SecretId: testReplicatedArn, ClientRequestToken: "version-1", Step: StepCreate}
+		if !assert.NoError(t, r.Handle(context.TODO(), event)) {
+			t.FailNow()
+		}
+		return event
+	}
+
+	t.Run("test waits for pending", func(t *testing.T) {
+		service := &mockService{OnCreate: StringSecret("v1")}
+		r, primary, replicas := newReplicated(t, service, map[string]int{"us-west-2": 3, "eu-west-1": 0})
+		event := pending(t, r, primary)
+
+		event.Step = StepTest
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assert.Len(t, service.TestCalled, 1)
+		assert.Equal(t, 4, replicas["us-west-2"].lookups, "should poll until the replica caught up")
+		assert.Equal(t, 1, replicas["eu-west-1"].lookups, "should stop polling replicas that caught up")
+	})
+
+	t.Run("test times out", func(t *testing.T) {
+		service := &mockService{OnCreate: StringSecret("v1")}
+		r, primary, _ := newReplicated(t, service, map[string]int{"us-west-2": 1000})
+		event := pending(t, r, primary)
+
+		event.Step = StepTest
+		err := r.Handle(context.TODO(), event)
+		var lagErr *ReplicaLagError
+		if assert.ErrorAs(t, err, &lagErr) {
+			assert.Equal(t, AWSPENDING, lagErr.Stage)
+			assert.Equal(t, []string{"us-west-2"}, lagErr.Regions)
+		}
+		assert.Empty(t, service.TestCalled, "the Service should not be tested until the replicas caught up")
+	})
+
+	t.Run("finish confirms current", func(t *testing.T) {
+		service := &mockService{OnCreate: StringSecret("v1")}
+		r, primary, replicas := newReplicated(t, service, map[string]int{"us-west-2": 1000})
+		event := pending(t, r, primary)
+
+		event.Step = StepFinish
+		assert.IsType(t, &ReplicaLagError{}, r.Handle(context.TODO(), event))
+		assert.Equal(t, "v1", primary.Current(testReplicatedArn))
+
+		// a retried finish has nothing left to do in the primary region, but still checks the replicas
+		replicas["us-west-2"].lag = 0
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assert.Equal(t, "v1", replicas["us-west-2"].Current(replicaSecretId(testReplicatedArn, "us-west-2")))
+	})
+
+	t.Run("discovers regions", func(t *testing.T) {
+		primary := newFakeSecretsManager(map[string]string{testReplicatedArn: "v0"})
+		replica := &laggingReplica{primary: primary, region: "ap-southeast-2", fakeSecretsManager: newFakeSecretsManager(nil)}
+		r := testRotator(t, &describingSecretsManager{primary, []string{"ap-southeast-2"}}, &mockService{OnCreate: StringSecret("v1")}).(*rotator)
+		r.replicas = newReplicas(&ReplicaConfig{
+			Client:   func(string) SecretsManagerApi { return replica },
+			Interval: time.Millisecond,
+		}, r.api)
+
+		event := pending(t, r, primary)
+		event.Step = StepTest
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assert.Equal(t, 1, replica.lookups)
+	})
+
+	t.Run("discovers regions with a Describer when the client cannot", func(t *testing.T) {
+		primary := newFakeSecretsManager(map[string]string{testReplicatedArn: "v0"})
+		replica := &laggingReplica{primary: primary, region: "ap-southeast-2", fakeSecretsManager: newFakeSecretsManager(nil)}
+		r := testRotator(t, primary, &mockService{OnCreate: StringSecret("v1")}).(*rotator)
+		r.replicas = newReplicas(&ReplicaConfig{
+			Describer: &describingSecretsManager{primary, []string{"ap-southeast-2"}},
+			Client:    func(string) SecretsManagerApi { return replica },
+			Interval:  time.Millisecond,
+		}, r.api)
+
+		event := pending(t, r, primary)
+		event.Step = StepTest
+		assert.NoError(t, r.Handle(context.TODO(), event))
+		assert.Equal(t, 1, replica.lookups)
+
+		r.replicas.Describer = nil
+		assert.ErrorContains(t, r.Handle(context.TODO(), event), "no Describer")
+	})
+
+	t.Run("reports a missing Client", func(t *testing.T) {
+		primary := newFakeSecretsManager(map[string]string{testReplicatedArn: "v0"})
+		service := &mockService{OnCreate: StringSecret("v1")}
+		r := testRotator(t, primary, service).(*rotator)
+		r.replicas = newReplicas(&ReplicaConfig{Regions: []string{"us-west-2"}}, r.api)
+
+		event := pending(t, r, primary)
+		event.Step = StepTest
+		assert.EqualError(t, r.Handle(context.TODO(), event), "replica checks require a Client for the replica regions")
+		assert.Empty(t, service.TestCalled)
+	})
+}
+
+func TestReplicaSecretId(t *testing.T) {
+	assert.Equal(t, "arn:aws:secretsmanager:eu-west-1:123456789012:secret:replicated-AbC123", replicaSecretId(testReplicatedArn, "eu-west-1"))
+	assert.Equal(t, "replicated", replicaSecretId("replicated", "eu-west-1"))
+}
+
+// laggingReplica copies the versions of a secret from its primary once it has been looked up lag times
+type laggingReplica struct {
+	*fakeSecretsManager
+	primary *fakeSecretsManager
+	region  string
+	lag     int
+	lookups int
+}
+
+func (l *laggingReplica) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	l.lookups++
+	if l.lookups > l.lag {
+		l.sync()
+	}
+	return l.fakeSecretsManager.GetSecretValue(ctx, params, optFns...)
+}
+
+func (l *laggingReplica) sync() {
+	for secretId, versions := range l.primary.secrets {
+		replicated := map[string]*fakeVersion{}
+		for versionId, version := range versions {
+			stages := map[string]bool{}
+			for stage := range version.stages {
+				stages[stage] = true
+			}
+			replicated[versionId] = &fakeVersion{value: version.value, stages: stages}
+		}
+		l.secrets[replicaSecretId(secretId, l.region)] = replicated
+	}
+}
+
+// describingSecretsManager reports the stages of each version, and that every secret is replicated to regions
+type describingSecretsManager struct {
+	*fakeSecretsManager
+	regions []string
+}
+
+func (d *describingSecretsManager) DescribeSecret(_ context.Context, params *secretsmanager.DescribeSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	output := &secretsmanager.DescribeSecretOutput{ARN: params.SecretId, VersionIdsToStages: d.Stages(*params.SecretId)}
+	for _, region := range d.regions {
+		output.ReplicationStatus = append(output.ReplicationStatus, types.ReplicationStatusType{Region: aws.String(region), Status: types.StatusTypeInSync})
+	}
+	return output, nil
+}
@@ -0,0 +1,195 @@
+// Package rotatehttp hosts a rotate.Handler behind an HTTP server, for rotations run outside Lambda
+// Rotation events are POSTed as JSON to /rotate, using the same document Secrets Manager sends to a rotation Lambda:
+//
+//	{"SecretId": "arn:aws:secretsmanager:...", "ClientRequestToken": "...", "Step": "createSecret"}
+package rotatehttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	RotatePath = "/rotate"
+	HealthPath = "/healthz"
+)
+
+type Config struct {
+	// Handler handles each rotation event
+	Handler rotate.Handler
+
+	// Verifier authenticates rotation requests, it is required and may be AllowAll when authentication is handled
+	// elsewhere, such as by a service mesh
+	Verifier Verifier
+
+	// Health optionally reports whether the server is ready to handle rotations
+	Health func(ctx context.Context) error
+
+	// MaxBodyBytes limits the size of a rotation request, defaults to 64KiB
+	MaxBodyBytes int64
+
+	// ShutdownTimeout limits how long Serve waits for in-flight rotations once its context is done, defaults to 30
+	// seconds
+	ShutdownTimeout time.Duration
+
+	// Logger defaults to a logger writing to stderr
+	Logger *log.Logger
+}
+
+// Server is an http.Handler serving rotation and health endpoints
+type Server struct {
+	handler         rotate.Handler
+	verifier        Verifier
+	health          func(ctx context.Context) error
+	maxBodyBytes    int64
+	shutdownTimeout time.Duration
+	logger          *log.Logger
+	mux             *http.ServeMux
+}
+
+// New returns a Server for c
+func New(c Config) (*Server, error) {
+	if c.Handler == nil {
+		return nil, errors.New("rotatehttp: Handler is required")
+	}
+	if c.Verifier == nil {
+		return nil, errors.New("rotatehttp: Verifier is required")
+	}
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = 64 << 10
+	}
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = 30 * time.Second
+	}
+	if c.Logger == nil {
+		c.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	s := &Server{
+		handler:         c.Handler,
+		verifier:        c.Verifier,
+		health:          c.Health,
+		maxBodyBytes:    c.MaxBodyBytes,
+		shutdownTimeout: c.ShutdownTimeout,
+		logger:          c.Logger,
+		mux:             http.NewServeMux(),
+	}
+	s.mux.HandleFunc(RotatePath, s.rotate)
+	s.mux.HandleFunc(HealthPath, s.healthz)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Response is the JSON body returned by every endpoint
+type Response struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *Server) rotate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		respond(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if err := s.verifier.Verify(r); err != nil {
+		s.logger.Printf("Rejected rotation request from %s: %s", r.RemoteAddr, err)
+		respond(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	var event rotate.Event
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes))
+	if err := decoder.Decode(&event); err != nil {
+		respond(w, http.StatusBadRequest, fmt.Errorf("invalid event: %w", err))
+		return
+	}
+	if event.SecretId == "" || event.ClientRequestToken == "" || event.Step == "" {
+		respond(w, http.StatusBadRequest, errors.New("invalid event: SecretId, ClientRequestToken and Step are required"))
+		return
+	}
+
+	if err := s.handler.Handle(r.Context(), event); err != nil {
+		s.logger.Printf("Rotation %s of %s failed: %s", event.Step, event.SecretId, err)
+		respond(w, http.StatusInternalServerError, err)
+		return
+	}
+	respond(w, http.StatusOK, nil)
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		respond(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if s.health != nil {
+		if err := s.health(r.Context()); err != nil {
+			respond(w, http.StatusServiceUnavailable, err)
+			return
+		}
+	}
+	respond(w, http.StatusOK, nil)
+}
+
+func respond(w http.ResponseWriter, status int, err error) {
+	response := Response{Status: "ok"}
+	if err != nil {
+		response = Response{Status: "error", Error: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// ListenAndServe listens on addr and serves until ctx is done, see Serve
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve accepts connections on listener until ctx is done, then shuts down gracefully
+// New requests are refused once ctx is done, while in-flight rotations are given ShutdownTimeout to complete.
+// It returns nil after a graceful shutdown.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          s.logger,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("rotatehttp: shutdown: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
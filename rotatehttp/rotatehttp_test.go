@@ -0,0 +1,190 @@
+package rotatehttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBody = `{"SecretId": "my-secret", "ClientRequestToken": "version-2", "Step": "createSecret"}`
+
+func testServer(t *testing.T, handler rotate.Handler, health func(context.Context) error) *httptest.Server {
+	s, err := New(Config{
+		Handler:  handler,
+		Verifier: BearerToken("old-token", "new-token"),
+		Health:   health,
+		Logger:   log.New(io.Discard, "", 0),
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func send(t *testing.T, server *httptest.Server, method, path, token, body string) (int, Response) {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := server.Client().Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	var response Response
+	if method != http.MethodHead {
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	}
+	return resp.StatusCode, response
+}
+
+func TestServer(t *testing.T) {
+	var handled []rotate.Event
+	handler := rotate.HandlerFunc(func(_ context.Context, event rotate.Event) error {
+		handled = append(handled, event)
+		if event.Step == rotate.StepTest {
+			return errors.New("pending secret rejected")
+		}
+		return nil
+	})
+	server := testServer(t, handler, nil)
+
+	t.Run("rotates", func(t *testing.T) {
+		handled = nil
+		status, response := send(t, server, http.MethodPost, RotatePath, "new-token", testBody)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "ok", response.Status)
+		assert.Equal(t, []rotate.Event{{SecretId: "my-secret", ClientRequestToken: "version-2", Step: rotate.StepCreate}}, handled)
+
+		// the previous token is still accepted while it is being rotated
+		status, _ = send(t, server, http.MethodPost, RotatePath, "old-token", testBody)
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("rotation errors", func(t *testing.T) {
+		status, response := send(t, server, http.MethodPost, RotatePath, "new-token", strings.Replace(testBody, "createSecret", "testSecret", 1))
+		assert.Equal(t, http.StatusInternalServerError, status)
+		assert.Equal(t, Response{Status: "error", Error: "pending secret rejected"}, response)
+	})
+
+	t.Run("rejected requests", func(t *testing.T) {
+		tests := map[string]struct {
+			method, token, body string
+			status              int
+		}{
+			"missing token":  {http.MethodPost, "", testBody, http.StatusUnauthorized},
+			"invalid token":  {http.MethodPost, "wrong-token", testBody, http.StatusUnauthorized},
+			"wrong method":   {http.MethodGet, "new-token", "", http.StatusMethodNotAllowed},
+			"malformed json": {http.MethodPost, "new-token", "{", http.StatusBadRequest},
+			"unknown step":   {http.MethodPost, "new-token", strings.Replace(testBody, "createSecret", "deleteSecret", 1), http.StatusBadRequest},
+			"missing fields": {http.MethodPost, "new-token", `{"Step": "createSecret"}`, http.StatusBadRequest},
+			"too large":      {http.MethodPost, "new-token", `{"SecretId": "` + strings.Repeat("a", 64<<10) + `"}`, http.StatusBadRequest},
+		}
+		for name, test := range tests {
+			t.Run(name, func(t *testing.T) {
+				handled = nil
+				status, response := send(t, server, test.method, RotatePath, test.token, test.body)
+				assert.Equal(t, test.status, status)
+				assert.Equal(t, "error", response.Status)
+				assert.Empty(t, handled)
+			})
+		}
+	})
+}
+
+func TestHealth(t *testing.T) {
+	var unhealthy error
+	server := testServer(t, rotate.HandlerFunc(func(context.Context, rotate.Event) error {
+		return nil
+	}), func(context.Context) error {
+		return unhealthy
+	})
+
+	// health checks do not require authentication
+	status, response := send(t, server, http.MethodGet, HealthPath, "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", response.Status)
+
+	unhealthy = errors.New("secrets manager unreachable")
+	status, response = send(t, server, http.MethodGet, HealthPath, "", "")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "secrets manager unreachable", response.Error)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Handler: rotate.HandlerFunc(nil)})
+	assert.Error(t, err, "a Verifier should be required")
+	_, err = New(Config{Verifier: AllowAll})
+	assert.Error(t, err, "a Handler should be required")
+}
+
+func TestServe(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	s, err := New(Config{
+		Handler: rotate.HandlerFunc(func(context.Context, rotate.Event) error {
+			close(started)
+			<-release
+			return nil
+		}),
+		Verifier: AllowAll,
+		Logger:   log.New(io.Discard, "", 0),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, listener)
+	}()
+
+	url := "http://" + listener.Addr().String() + RotatePath
+	responses := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(url, "application/json", strings.NewReader(testBody))
+		if err != nil {
+			responses <- 0
+			return
+		}
+		resp.Body.Close()
+		responses <- resp.StatusCode
+	}()
+
+	// shut down while a rotation is in flight
+	<-started
+	cancel()
+	select {
+	case <-served:
+		t.Fatal("server stopped before the in-flight rotation completed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-responses)
+	assert.NoError(t, <-served)
+
+	_, err = http.Post(url, "application/json", strings.NewReader(testBody))
+	assert.Error(t, err, "new connections should be refused after shutdown")
+}
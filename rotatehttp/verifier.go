@@ -0,0 +1,49 @@
+package rotatehttp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Verifier authenticates a rotation request, returning an error to reject it
+// The request body must not be consumed.
+type Verifier interface {
+	Verify(r *http.Request) error
+}
+
+// VerifierFunc adapts an ordinary function to a Verifier
+type VerifierFunc func(r *http.Request) error
+
+func (f VerifierFunc) Verify(r *http.Request) error {
+	return f(r)
+}
+
+// AllowAll is a Verifier that accepts every request
+var AllowAll Verifier = VerifierFunc(func(*http.Request) error {
+	return nil
+})
+
+// BearerToken returns a Verifier accepting requests with an `Authorization: Bearer <token>` header matching any of
+// tokens, several tokens allow the shared token to be rotated without downtime
+func BearerToken(tokens ...string) Verifier {
+	return VerifierFunc(func(r *http.Request) error {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			return errors.New("missing bearer token")
+		}
+		presented := []byte(strings.TrimPrefix(header, "Bearer "))
+
+		matched := 0
+		for _, token := range tokens {
+			if token != "" {
+				matched |= subtle.ConstantTimeCompare(presented, []byte(token))
+			}
+		}
+		if matched != 1 {
+			return errors.New("invalid bearer token")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,240 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretDescriber is the subset of the Secrets Manager client used to look up the tags of a secret
+type SecretDescriber interface {
+	DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
+}
+
+// Route rotates the secrets matched by Match with Service
+type Route struct {
+	Match   Matcher
+	Service Service
+}
+
+// Matcher decides whether a Route applies to a secret
+type Matcher interface {
+	Match(ctx context.Context, secret *RoutedSecret) (bool, error)
+}
+
+// MatcherFunc adapts an ordinary function to a Matcher
+type MatcherFunc func(ctx context.Context, secret *RoutedSecret) (bool, error)
+
+func (f MatcherFunc) Match(ctx context.Context, secret *RoutedSecret) (bool, error) {
+	return f(ctx, secret)
+}
+
+// RoutedSecret is the secret a Router is choosing a Service for
+type RoutedSecret struct {
+	// Id is the SecretId of the event, normally the ARN of the secret
+	Id string
+
+	describer SecretDescriber
+	timeout   time.Duration
+	tags      map[string]string
+}
+
+// secretArnSuffix is the random suffix Secrets Manager adds to the name of a secret in its ARN
+var secretArnSuffix = regexp.MustCompile(`-[A-Za-z0-9]{6}$`)
+
+// Name returns the name of the secret, without the random suffix included in its ARN
+func (s *RoutedSecret) Name() string {
+	const marker = ":secret:"
+	if !strings.HasPrefix(s.Id, "arn:") {
+		return s.Id
+	}
+	idx := strings.Index(s.Id, marker)
+	if idx < 0 {
+		return s.Id
+	}
+	return secretArnSuffix.ReplaceAllString(s.Id[idx+len(marker):], "")
+}
+
+// Tags looks up the tags of the secret with DescribeSecret, the lookup is only made once for each RoutedSecret
+func (s *RoutedSecret) Tags(ctx context.Context) (map[string]string, error) {
+	if s.tags != nil {
+		return s.tags, nil
+	}
+	if s.describer == nil {
+		return nil, fmt.Errorf("routing by tag requires a SecretDescriber")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	output, err := s.describer.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &s.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	s.tags = make(map[string]string, len(output.Tags))
+	for _, tag := range output.Tags {
+		if tag.Key != nil && tag.Value != nil {
+			s.tags[*tag.Key] = *tag.Value
+		}
+	}
+	return s.tags, nil
+}
+
+// ARNGlob matches secrets whose SecretId matches pattern, where * matches any run of characters and ? matches one
+// e.g. `arn:aws:secretsmanager:*:*:secret:prod/db/*`
+func ARNGlob(pattern string) Matcher {
+	var expr strings.Builder
+	expr.WriteByte('^')
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteByte('.')
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteByte('$')
+	return ARNRegexp(regexp.MustCompile(expr.String()))
+}
+
+// ARNRegexp matches secrets whose SecretId matches re
+func ARNRegexp(re *regexp.Regexp) Matcher {
+	return MatcherFunc(func(_ context.Context, secret *RoutedSecret) (bool, error) {
+		return re.MatchString(secret.Id), nil
+	})
+}
+
+// NamePrefix matches secrets whose name starts with prefix
+func NamePrefix(prefix string) Matcher {
+	return MatcherFunc(func(_ context.Context, secret *RoutedSecret) (bool, error) {
+		return strings.HasPrefix(secret.Name(), prefix), nil
+	})
+}
+
+// Tag matches secrets tagged with key, and value when it is not empty
+func Tag(key, value string) Matcher {
+	return MatcherFunc(func(ctx context.Context, secret *RoutedSecret) (bool, error) {
+		tags, err := secret.Tags(ctx)
+		if err != nil {
+			return false, err
+		}
+		actual, ok := tags[key]
+		return ok && (value == "" || actual == value), nil
+	})
+}
+
+type RouterConfig struct {
+	// Config is shared by every route, its Service is used for secrets that no Route matches
+	Config
+
+	// Routes are evaluated in order, the first to match a secret rotates it
+	Routes []Route
+
+	// Describer looks up tags for the Tag matcher, defaults to SecretsManager when it implements SecretDescriber
+	Describer SecretDescriber
+
+	// CacheTTL is how long the route chosen for a secret is remembered, defaults to 10 minutes
+	CacheTTL time.Duration
+}
+
+// NewRouter returns a Handler that rotates each secret with the Service of the first Route that matches it
+func NewRouter(c RouterConfig) Handler {
+	if c.Describer == nil {
+		c.Describer, _ = c.SecretsManager.(SecretDescriber)
+	}
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = 10 * time.Minute
+	}
+
+	r := &router{
+		routes:    c.Routes,
+		rotators:  make([]*rotator, len(c.Routes)),
+		describer: c.Describer,
+		timeout:   c.Timeout,
+		cacheTTL:  c.CacheTTL,
+		now:       time.Now,
+		cache:     make(map[string]routeCacheEntry),
+	}
+	for i, route := range c.Routes {
+		routeConfig := c.Config
+		routeConfig.Service = route.Service
+		r.rotators[i] = newRotator(routeConfig)
+	}
+	if c.Service != nil {
+		r.fallback = newRotator(c.Config)
+	}
+	if r.timeout <= 0 {
+		r.timeout = time.Second
+	}
+	return wrap(r, c.Middleware)
+}
+
+type router struct {
+	routes    []Route
+	rotators  []*rotator
+	fallback  *rotator
+	describer SecretDescriber
+	timeout   time.Duration
+	cacheTTL  time.Duration
+	now       func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]routeCacheEntry
+}
+
+type routeCacheEntry struct {
+	rotator *rotator
+	expires time.Time
+}
+
+func (r *router) Handle(ctx context.Context, event Event) error {
+	rotator, err := r.resolve(ctx, event.SecretId)
+	if err != nil {
+		return err
+	}
+	return rotator.Handle(ctx, event)
+}
+
+// resolve returns the rotator for secretId, consulting the cache before evaluating each route
+func (r *router) resolve(ctx context.Context, secretId string) (*rotator, error) {
+	r.mu.Lock()
+	entry, ok := r.cache[secretId]
+	r.mu.Unlock()
+	if ok && r.now().Before(entry.expires) {
+		return entry.rotator, nil
+	}
+
+	secret := &RoutedSecret{Id: secretId, describer: r.describer, timeout: r.timeout}
+	chosen := r.fallback
+	for i, route := range r.routes {
+		matched, err := route.Match.Match(ctx, secret)
+		if err != nil {
+			return nil, fmt.Errorf("routing secret %s: %w", secretId, err)
+		}
+		if matched {
+			chosen = r.rotators[i]
+			break
+		}
+	}
+	if chosen == nil {
+		return nil, fmt.Errorf("no route matches secret %s and there is no default Service", secretId)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	// secrets that are no longer rotated would otherwise be remembered forever
+	for cached, entry := range r.cache {
+		if !now.Before(entry.expires) {
+			delete(r.cache, cached)
+		}
+	}
+	r.cache[secretId] = routeCacheEntry{rotator: chosen, expires: now.Add(r.cacheTTL)}
+	return chosen, nil
+}
@@ -0,0 +1,172 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const (
+	testDatabaseArn = "arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/database/password-AbC123"
+	testApiKeyArn   = "arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/api-key-XyZ789"
+	testTaggedArn   = "arn:aws:secretsmanager:us-east-1:123456789012:secret:legacy-Qwe456"
+)
+
+func TestRoutedSecret(t *testing.T) {
+	names := map[string]string{
+		testDatabaseArn:   "prod/database/password",
+		testApiKeyArn:     "prod/api-key",
+		"prod/plain-name": "prod/plain-name",
+		"arn:aws:secretsmanager:us-east-1:123456789012:secret:short": "short",
+	}
+	for id, name := range names {
+		assert.Equal(t, name, (&RoutedSecret{Id: id}).Name(), id)
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	secret := &RoutedSecret{Id: testDatabaseArn}
+	matches := func(m Matcher) bool {
+		matched, err := m.Match(context.TODO(), secret)
+		assert.NoError(t, err)
+		return matched
+	}
+
+	assert.True(t, matches(ARNGlob("arn:aws:secretsmanager:*:123456789012:secret:prod/database/*")))
+	assert.True(t, matches(ARNGlob("*:secret:prod/*-??????")))
+	assert.False(t, matches(ARNGlob("arn:aws:secretsmanager:*:210987654321:secret:*")))
+	assert.False(t, matches(ARNGlob("*:secret:prod.database*")), "glob should escape regular expression characters")
+	assert.True(t, matches(ARNRegexp(regexp.MustCompile(`:secret:prod/database/`))))
+	assert.True(t, matches(NamePrefix("prod/database/")))
+	assert.False(t, matches(NamePrefix("staging/")))
+}
+
+func TestRouter(t *testing.T) {
+	newRouter := func(describer SecretDescriber, routes []Route, fallback Service) *router {
+		r := NewRouter(RouterConfig{
+			Config:    Config{SecretsManager: routerSecretsManager(), Service: fallback},
+			Routes:    routes,
+			Describer: describer,
+		}).(*router)
+		for _, rotator := range append(r.rotators, r.fallback) {
+			if rotator != nil {
+				rotator.logger = log.New(io.Discard, "", 0)
+			}
+		}
+		return r
+	}
+	event := func(secretId string) Event {
+		e := testEvent(StepCreate)
+		e.SecretId = secretId
+		return e
+	}
+
+	t.Run("routes by first match", func(t *testing.T) {
+		database := &mockService{OnCreate: StringSecret("database")}
+		apiKey := &mockService{OnCreate: StringSecret("api-key")}
+		tagged := &mockService{OnCreate: StringSecret("tagged")}
+		fallback := &mockService{OnCreate: StringSecret("fallback")}
+		describer := &mockDescriber{Tags: map[string]map[string]string{testTaggedArn: {"rotation": "legacy"}}}
+
+		r := newRouter(describer, []Route{
+			{Match: ARNGlob("*:secret:prod/database/*"), Service: database},
+			{Match: NamePrefix("prod/"), Service: apiKey},
+			{Match: Tag("rotation", "legacy"), Service: tagged},
+		}, fallback)
+
+		for _, id := range []string{testDatabaseArn, testApiKeyArn, testTaggedArn, "arn:aws:secretsmanager:us-east-1:123456789012:secret:other-Rty012"} {
+			assert.NoError(t, r.Handle(context.TODO(), event(id)))
+		}
+		assert.Len(t, database.CreateCalled, 1)
+		assert.Len(t, apiKey.CreateCalled, 1)
+		assert.Len(t, tagged.CreateCalled, 1)
+		assert.Len(t, fallback.CreateCalled, 1)
+		// tags are only looked up for secrets that reach a tag route
+		assert.Equal(t, []string{testTaggedArn, "arn:aws:secretsmanager:us-east-1:123456789012:secret:other-Rty012"}, describer.Described)
+	})
+
+	t.Run("caches resolution", func(t *testing.T) {
+		tagged := &mockService{OnCreate: StringSecret("tagged")}
+		describer := &mockDescriber{Tags: map[string]map[string]string{testTaggedArn: {"rotation": "legacy"}}}
+		r := newRouter(describer, []Route{{Match: Tag("rotation", ""), Service: tagged}}, nil)
+		now := time.Now()
+		r.now = func() time.Time { return now }
+
+		assert.NoError(t, r.Handle(context.TODO(), event(testTaggedArn)))
+		assert.NoError(t, r.Handle(context.TODO(), event(testTaggedArn)))
+		assert.Len(t, describer.Described, 1)
+
+		now = now.Add(11 * time.Minute)
+		assert.NoError(t, r.Handle(context.TODO(), event(testTaggedArn)))
+		assert.Len(t, describer.Described, 2)
+		assert.Len(t, tagged.CreateCalled, 3)
+	})
+
+	t.Run("evicts expired resolutions", func(t *testing.T) {
+		r := newRouter(nil, []Route{{Match: NamePrefix("prod/"), Service: &mockService{OnCreate: StringSecret("prod")}}}, nil)
+		now := time.Now()
+		r.now = func() time.Time { return now }
+
+		assert.NoError(t, r.Handle(context.TODO(), event(testDatabaseArn)))
+		now = now.Add(11 * time.Minute)
+		assert.NoError(t, r.Handle(context.TODO(), event(testApiKeyArn)))
+		assert.Len(t, r.cache, 1)
+		assert.Contains(t, r.cache, testApiKeyArn)
+	})
+
+	t.Run("no match without default", func(t *testing.T) {
+		r := newRouter(nil, []Route{{Match: NamePrefix("staging/"), Service: &mockService{}}}, nil)
+		err := r.Handle(context.TODO(), event(testDatabaseArn))
+		assert.ErrorContains(t, err, "no route matches secret "+testDatabaseArn)
+	})
+
+	t.Run("tag lookup errors are not cached", func(t *testing.T) {
+		denied := errors.New("access denied")
+		describer := &mockDescriber{Err: denied}
+		r := newRouter(describer, []Route{{Match: Tag("rotation", "legacy"), Service: &mockService{}}}, &mockService{})
+
+		assert.ErrorIs(t, r.Handle(context.TODO(), event(testTaggedArn)), denied)
+		assert.ErrorIs(t, r.Handle(context.TODO(), event(testTaggedArn)), denied)
+		assert.Len(t, describer.Described, 2)
+	})
+
+	t.Run("tag routes require a describer", func(t *testing.T) {
+		r := newRouter(nil, []Route{{Match: Tag("rotation", "legacy"), Service: &mockService{}}}, &mockService{})
+		assert.ErrorContains(t, r.Handle(context.TODO(), event(testTaggedArn)), "SecretDescriber")
+	})
+}
+
+// routerSecretsManager returns a current version for any secret
+func routerSecretsManager() *mockSecretsManager {
+	return &mockSecretsManager{
+		Existing: map[string]*secretsmanager.GetSecretValueOutput{
+			AWSCURRENT: {VersionId: testVersionId(), SecretString: aws.String("current")},
+		},
+	}
+}
+
+type mockDescriber struct {
+	Tags      map[string]map[string]string
+	Err       error
+	Described []string
+}
+
+func (m *mockDescriber) DescribeSecret(_ context.Context, params *secretsmanager.DescribeSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	m.Described = append(m.Described, *params.SecretId)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	output := &secretsmanager.DescribeSecretOutput{ARN: params.SecretId}
+	for k, v := range m.Tags[*params.SecretId] {
+		output.Tags = append(output.Tags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return output, nil
+}
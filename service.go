@@ -28,12 +28,35 @@ type FinishingService interface {
 	Finish(ctx context.Context, pending Secret) error
 }
 
+// RollbackService is a Service that can undo the changes it made while rotating, when a Group fails to rotate
+// Rollback is called with the same secrets as Set for every member whose Set step was attempted, even when Set failed
+// part way through or the member had already been finished.
+type RollbackService interface {
+	Service
+	Rollback(ctx context.Context, current Secret, pending Secret) error
+}
+
+// CleaningService is a Service that wants to clean up after a version abandoned by an earlier rotation
+// Clean is called during the create step with the version that AWSPENDING was left attached to, so that any external
+// credential created for it can be revoked, before AWSPENDING is removed from it.
+type CleaningService interface {
+	Service
+	Clean(ctx context.Context, orphaned Secret) error
+}
+
 // ParsingService is a Service that wants each Secret to pass through a SecretParser before actions are performed
 type ParsingService interface {
 	Service
 	SecretParser
 }
 
+// ValidatingService is a Service that wants each Secret validated in its raw form, before it is parsed and after it is
+// encoded to be written. A validate.Schema can be used as the Validate method.
+type ValidatingService interface {
+	Service
+	Validate(Secret) error
+}
+
 type Secret interface {
 	// Binary indicates if the secret is binary or string format
 	Binary() bool
@@ -43,6 +66,23 @@ type Secret interface {
 	Value() ([]byte, error)
 }
 
+// ValidatingSecret is a Secret that can verify its own contents
+// Validate is called on every parsed secret, as well as each newly created secret before it is encoded and stored.
+// Struct tags are not checked by the rotator, Validate can check them by calling validate.Struct.
+type ValidatingSecret interface {
+	Secret
+	Validate() error
+}
+
+// DestroyableSecret is a Secret that holds material which can be cleared from memory
+// Destroy is called on every secret loaded, parsed or created during a step once that step completes, so a secret
+// must not be retained beyond the step that produced it. Destroy may be called more than once.
+type DestroyableSecret interface {
+	Secret
+	Destroy()
+}
+
+// StringSecret is immutable and cannot be cleared from memory, use BinarySecret when zeroization is required
 type StringSecret string
 
 func (s StringSecret) Binary() bool {
@@ -64,12 +104,20 @@ func (b BinarySecret) Binary() bool {
 	return true
 }
 
+// Value returns the underlying bytes rather than a copy, so that Destroy clears every reference the rotator holds
 func (b BinarySecret) Value() ([]byte, error) {
 	return b, nil
 }
 
-// Ensure that BinarySecret remains Secret compatible
-func _(s BinarySecret) Secret {
+// Destroy overwrites the secret with zeroes
+func (b BinarySecret) Destroy() {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Ensure that BinarySecret remains DestroyableSecret compatible
+func _(s BinarySecret) DestroyableSecret {
 	return s
 }
 
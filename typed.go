@@ -0,0 +1,224 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+)
+
+// Codec converts between a raw Secret and its typed representation T
+type Codec[T any] interface {
+	Decode(Secret) (T, error)
+	Encode(T) (Secret, error)
+}
+
+// TypedService is responsible for creating an updated secret value of type T
+type TypedService[T any] interface {
+	Create(ctx context.Context, current T) (T, error)
+}
+
+// TypedSettingService is a TypedService that wants to perform actions during the SET phase
+type TypedSettingService[T any] interface {
+	TypedService[T]
+	Set(ctx context.Context, current T, pending T) error
+}
+
+// TypedTestingService is a TypedService that wants to perform actions during the TEST phase
+type TypedTestingService[T any] interface {
+	TypedService[T]
+	Test(ctx context.Context, pending T) error
+}
+
+// TypedFinishingService is a TypedService that wants to perform actions during the FINISH phase
+type TypedFinishingService[T any] interface {
+	TypedService[T]
+	Finish(ctx context.Context, pending T) error
+}
+
+// TypedRollbackService is a TypedService that can undo the changes it made when a Group fails to rotate
+type TypedRollbackService[T any] interface {
+	TypedService[T]
+	Rollback(ctx context.Context, current T, pending T) error
+}
+
+// TypedCleaningService is a TypedService that wants to clean up after a version abandoned by an earlier rotation
+type TypedCleaningService[T any] interface {
+	TypedService[T]
+	Clean(ctx context.Context, orphaned T) error
+}
+
+// TypedFreshnessService is a TypedService that only wants to create a new version once the current one is due for
+// rotation, see FreshnessService. A TypedService can instead embed a FreshnessPolicy such as MinAge.
+type TypedFreshnessService[T any] interface {
+	TypedService[T]
+	ShouldRotate(ctx context.Context, current T, version SecretVersion) (rotate bool, reason string, err error)
+}
+
+// Typed adapts a TypedService into a Service, every secret is decoded with codec before it reaches svc
+// The returned Service intercepts every step, hooks that svc does not implement do nothing. It is a FreshnessService
+// when svc is a TypedFreshnessService or a FreshnessPolicy.
+func Typed[T any](codec Codec[T], svc TypedService[T]) Service {
+	typed := &typedService[T]{codec: codec, service: svc}
+	switch svc.(type) {
+	case TypedFreshnessService[T], FreshnessPolicy:
+		return &typedFreshnessService[T]{typed}
+	}
+	return typed
+}
+
+type typedService[T any] struct {
+	codec   Codec[T]
+	service TypedService[T]
+}
+
+// typedFreshnessService forwards ShouldRotate to a TypedService that decides when to rotate
+type typedFreshnessService[T any] struct {
+	*typedService[T]
+}
+
+func (t *typedFreshnessService[T]) ShouldRotate(ctx context.Context, current Secret, version SecretVersion) (bool, string, error) {
+	if policy, ok := t.service.(FreshnessPolicy); ok {
+		return policy.ShouldRotate(ctx, current, version)
+	}
+	value, err := t.unwrap(current)
+	if err != nil {
+		return false, "", err
+	}
+	return t.service.(TypedFreshnessService[T]).ShouldRotate(ctx, value, version)
+}
+
+// typedSecret is a Secret that has been decoded by a Codec
+type typedSecret[T any] struct {
+	Redacted
+	value T
+	codec Codec[T]
+}
+
+func (s *typedSecret[T]) Binary() bool {
+	encoded, err := s.codec.Encode(s.value)
+	return err == nil && encoded.Binary()
+}
+
+func (s *typedSecret[T]) Value() ([]byte, error) {
+	encoded, err := s.codec.Encode(s.value)
+	if err != nil {
+		return nil, err
+	}
+	return encoded.Value()
+}
+
+// Validate defers to the decoded value when T has its own Validate() error method
+func (s *typedSecret[T]) Validate() error {
+	if validating, ok := any(s.value).(interface{ Validate() error }); ok {
+		return validating.Validate()
+	}
+	return nil
+}
+
+// Destroy defers to the decoded value when T has its own Destroy() method
+func (s *typedSecret[T]) Destroy() {
+	if destroyable, ok := any(s.value).(interface{ Destroy() }); ok {
+		destroyable.Destroy()
+	}
+}
+
+func (t *typedService[T]) Parse(secret Secret) (Secret, error) {
+	value, err := t.codec.Decode(secret)
+	if err != nil {
+		return secret, err
+	}
+	return &typedSecret[T]{value: value, codec: t.codec}, nil
+}
+
+func (t *typedService[T]) Create(ctx context.Context, current Secret) (Secret, error) {
+	value, err := t.unwrap(current)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := t.service.Create(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	return &typedSecret[T]{value: pending, codec: t.codec}, nil
+}
+
+func (t *typedService[T]) Set(ctx context.Context, current Secret, pending Secret) error {
+	setter, ok := t.service.(TypedSettingService[T])
+	if !ok {
+		return nil
+	}
+
+	currentValue, err := t.unwrap(current)
+	if err != nil {
+		return err
+	}
+	pendingValue, err := t.unwrap(pending)
+	if err != nil {
+		return err
+	}
+	return setter.Set(ctx, currentValue, pendingValue)
+}
+
+func (t *typedService[T]) Test(ctx context.Context, pending Secret) error {
+	tester, ok := t.service.(TypedTestingService[T])
+	if !ok {
+		return nil
+	}
+
+	value, err := t.unwrap(pending)
+	if err != nil {
+		return err
+	}
+	return tester.Test(ctx, value)
+}
+
+func (t *typedService[T]) Finish(ctx context.Context, pending Secret) error {
+	finisher, ok := t.service.(TypedFinishingService[T])
+	if !ok {
+		return nil
+	}
+
+	value, err := t.unwrap(pending)
+	if err != nil {
+		return err
+	}
+	return finisher.Finish(ctx, value)
+}
+
+func (t *typedService[T]) Rollback(ctx context.Context, current Secret, pending Secret) error {
+	rollbacker, ok := t.service.(TypedRollbackService[T])
+	if !ok {
+		return nil
+	}
+
+	currentValue, err := t.unwrap(current)
+	if err != nil {
+		return err
+	}
+	pendingValue, err := t.unwrap(pending)
+	if err != nil {
+		return err
+	}
+	return rollbacker.Rollback(ctx, currentValue, pendingValue)
+}
+
+func (t *typedService[T]) Clean(ctx context.Context, orphaned Secret) error {
+	cleaner, ok := t.service.(TypedCleaningService[T])
+	if !ok {
+		return nil
+	}
+
+	value, err := t.unwrap(orphaned)
+	if err != nil {
+		return err
+	}
+	return cleaner.Clean(ctx, value)
+}
+
+func (t *typedService[T]) unwrap(secret Secret) (T, error) {
+	if typed, ok := secret.(*typedSecret[T]); ok {
+		return typed.value, nil
+	}
+	var zero T
+	return zero, fmt.Errorf("rotate: secret of type %T was not decoded by the typed service", secret)
+}
@@ -0,0 +1,247 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestTyped(t *testing.T) {
+	t.Run("create receives and returns decoded values", func(t *testing.T) {
+		event := testEvent(StepCreate)
+		current := "41"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &current},
+			},
+		}
+		svc := &counterService{}
+
+		assert.NoError(t, testRotator(t, sm, Typed[int](intCodec{}, svc)).Handle(context.TODO(), event))
+		assert.Equal(t, []int{41}, svc.created)
+		if assert.Len(t, sm.Creations, 1) {
+			assert.Equal(t, "42", *sm.Creations[0].SecretString)
+		}
+	})
+
+	t.Run("set, test and finish receive decoded values", func(t *testing.T) {
+		current, pending := "1", "2"
+		svc := &counterService{}
+
+		for _, step := range []Step{StepSet, StepTest, StepFinish} {
+			event := testEvent(step)
+			sm := &mockSecretsManager{
+				Existing: map[string]*secretsmanager.GetSecretValueOutput{
+					AWSCURRENT: {VersionId: testVersionId(), SecretString: &current},
+					AWSPENDING: {VersionId: &event.ClientRequestToken, SecretString: &pending},
+				},
+			}
+			assert.NoError(t, testRotator(t, sm, Typed[int](intCodec{}, svc)).Handle(context.TODO(), event))
+		}
+
+		assert.Equal(t, [][2]int{{1, 2}}, svc.set)
+		assert.Equal(t, []int{2}, svc.tested)
+		assert.Equal(t, []int{2}, svc.finished)
+	})
+
+	t.Run("hooks not implemented by the typed service do nothing", func(t *testing.T) {
+		svc := Typed[int](intCodec{}, createOnlyService{})
+		parsed, err := svc.(ParsingService).Parse(StringSecret("7"))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.NoError(t, svc.(SettingService).Set(context.TODO(), parsed, parsed))
+		assert.NoError(t, svc.(TestingService).Test(context.TODO(), parsed))
+		assert.NoError(t, svc.(FinishingService).Finish(context.TODO(), parsed))
+		assert.NoError(t, svc.(RollbackService).Rollback(context.TODO(), parsed, parsed))
+		assert.NoError(t, svc.(CleaningService).Clean(context.TODO(), parsed))
+	})
+
+	t.Run("rollback and clean receive decoded values", func(t *testing.T) {
+		svc := &counterService{}
+		typed := Typed[int](intCodec{}, svc)
+		current, err := typed.(ParsingService).Parse(StringSecret("1"))
+		assert.NoError(t, err)
+		pending, err := typed.(ParsingService).Parse(StringSecret("2"))
+		assert.NoError(t, err)
+
+		assert.NoError(t, typed.(RollbackService).Rollback(context.TODO(), current, pending))
+		assert.NoError(t, typed.(CleaningService).Clean(context.TODO(), pending))
+		assert.Equal(t, [][2]int{{1, 2}}, svc.rolledBack)
+		assert.Equal(t, []int{2}, svc.cleaned)
+	})
+
+	t.Run("freshness is decided by the typed service", func(t *testing.T) {
+		policy := FreshnessPolicyFunc(func(context.Context, Secret, SecretVersion) (bool, string, error) {
+			return false, "the policy says so", nil
+		})
+		for name, svc := range map[string]TypedService[int]{
+			"typed": &freshCounterService{},
+			"embedded": &struct {
+				createOnlyService
+				FreshnessPolicy
+			}{FreshnessPolicy: policy},
+		} {
+			t.Run(name, func(t *testing.T) {
+				current := "5"
+				sm := &mockSecretsManager{
+					Existing: map[string]*secretsmanager.GetSecretValueOutput{
+						AWSCURRENT: {VersionId: testVersionId(), SecretString: &current},
+					},
+				}
+				typed := Typed[int](intCodec{}, svc)
+				assert.Implements(t, (*FreshnessService)(nil), typed)
+				assert.NoError(t, testRotator(t, sm, typed).Handle(context.TODO(), testEvent(StepCreate)))
+				assert.Empty(t, sm.Creations)
+			})
+		}
+
+		current := "41"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &current},
+			},
+		}
+		assert.NoError(t, testRotator(t, sm, Typed[int](intCodec{}, &freshCounterService{})).Handle(context.TODO(), testEvent(StepCreate)))
+		assert.Len(t, sm.Creations, 1)
+		_, ok := Typed[int](intCodec{}, createOnlyService{}).(FreshnessService)
+		assert.False(t, ok, "services without a freshness hook are always rotated")
+	})
+
+	t.Run("decode errors are returned from parsing", func(t *testing.T) {
+		_, err := Typed[int](intCodec{}, createOnlyService{}).(ParsingService).Parse(StringSecret("not a number"))
+		assert.Error(t, err)
+	})
+
+	t.Run("secrets that were not decoded are rejected", func(t *testing.T) {
+		_, err := Typed[int](intCodec{}, createOnlyService{}).Create(context.TODO(), StringSecret("7"))
+		assert.Error(t, err)
+	})
+
+	t.Run("decoded values with a Validate method are validated by the rotator", func(t *testing.T) {
+		event := testEvent(StepCreate)
+		current := "-1"
+		sm := &mockSecretsManager{
+			Existing: map[string]*secretsmanager.GetSecretValueOutput{
+				AWSCURRENT: {VersionId: testVersionId(), SecretString: &current},
+			},
+		}
+
+		err := testRotator(t, sm, Typed[positive](positiveCodec{}, positiveService{})).Handle(context.TODO(), event)
+		assert.EqualError(t, err, "invalid AWSCURRENT secret: must be positive")
+		assert.Empty(t, sm.Creations)
+	})
+
+	t.Run("decoded secrets encode back to their raw value", func(t *testing.T) {
+		parsed, err := Typed[int](intCodec{}, createOnlyService{}).(ParsingService).Parse(StringSecret("7"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		value, err := parsed.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, "7", string(value))
+		assert.False(t, parsed.Binary())
+	})
+}
+
+type intCodec struct{}
+
+func (intCodec) Decode(secret Secret) (int, error) {
+	value, err := secret.Value()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(value))
+}
+
+func (intCodec) Encode(value int) (Secret, error) {
+	return StringSecret(strconv.Itoa(value)), nil
+}
+
+type counterService struct {
+	created  []int
+	set      [][2]int
+	tested   []int
+	finished []int
+
+	rolledBack [][2]int
+	cleaned    []int
+}
+
+func (c *counterService) Create(_ context.Context, current int) (int, error) {
+	c.created = append(c.created, current)
+	return current + 1, nil
+}
+
+func (c *counterService) Set(_ context.Context, current int, pending int) error {
+	c.set = append(c.set, [2]int{current, pending})
+	return nil
+}
+
+func (c *counterService) Test(_ context.Context, pending int) error {
+	c.tested = append(c.tested, pending)
+	return nil
+}
+
+func (c *counterService) Finish(_ context.Context, pending int) error {
+	c.finished = append(c.finished, pending)
+	return nil
+}
+
+func (c *counterService) Rollback(_ context.Context, current int, pending int) error {
+	c.rolledBack = append(c.rolledBack, [2]int{current, pending})
+	return nil
+}
+
+func (c *counterService) Clean(_ context.Context, orphaned int) error {
+	c.cleaned = append(c.cleaned, orphaned)
+	return nil
+}
+
+// freshCounterService only rotates counters that have reached 10
+type freshCounterService struct {
+	counterService
+}
+
+func (f *freshCounterService) ShouldRotate(_ context.Context, current int, _ SecretVersion) (bool, string, error) {
+	if current < 10 {
+		return false, "the counter is below 10", nil
+	}
+	return true, "the counter has reached 10", nil
+}
+
+type createOnlyService struct{}
+
+func (createOnlyService) Create(_ context.Context, current int) (int, error) {
+	return current + 1, nil
+}
+
+type positive int
+
+func (p positive) Validate() error {
+	if p <= 0 {
+		return errors.New("must be positive")
+	}
+	return nil
+}
+
+type positiveCodec struct{}
+
+func (positiveCodec) Decode(secret Secret) (positive, error) {
+	value, err := intCodec{}.Decode(secret)
+	return positive(value), err
+}
+
+func (positiveCodec) Encode(value positive) (Secret, error) {
+	return intCodec{}.Encode(int(value))
+}
+
+type positiveService struct{}
+
+func (positiveService) Create(_ context.Context, current positive) (positive, error) {
+	return current + 1, nil
+}
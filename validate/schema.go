@@ -0,0 +1,320 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema validates JSON secrets against a JSON Schema document
+// The following keywords are supported, any others are ignored:
+//
+//	type, enum, const, properties, required, additionalProperties, items, minItems, maxItems,
+//	minLength, maxLength, pattern, minimum, maximum
+type Schema struct {
+	root *node
+}
+
+// CompileSchema parses a JSON Schema document
+func CompileSchema(document []byte) (*Schema, error) {
+	root, err := compile(document, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// Validate checks the JSON value of secret against the schema, returning a *FieldError for the first violation
+// It is meant to be the Validate method of a rotate.ValidatingService, which is given the raw document rather than a
+// parsed secret that may have filled in missing fields when encoded again.
+func (s *Schema) Validate(secret rotate.Secret) error {
+	data, err := secret.Value()
+	if err != nil {
+		return err
+	}
+
+	var document interface{}
+	if err = json.Unmarshal(data, &document); err != nil {
+		return &FieldError{Reason: "is not a JSON document"}
+	}
+	return s.root.validate(document, "")
+}
+
+type rawNode struct {
+	Type                 json.RawMessage            `json:"type"`
+	Enum                 []interface{}              `json:"enum"`
+	Const                json.RawMessage            `json:"const"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              string                     `json:"pattern"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+}
+
+type node struct {
+	types      []string
+	enum       []interface{}
+	constant   interface{}
+	hasConst   bool
+	properties map[string]*node
+	required   []string
+	// additional is nil when additional properties are allowed without constraint
+	additional *node
+	forbidden  bool
+	items      *node
+	minItems   *int
+	maxItems   *int
+	minLength  *int
+	maxLength  *int
+	pattern    *regexp.Regexp
+	minimum    *float64
+	maximum    *float64
+}
+
+func compile(document []byte, path string) (*node, error) {
+	// boolean schemas accept (true) or reject (false) everything
+	switch strings.TrimSpace(string(document)) {
+	case "true":
+		return &node{}, nil
+	case "false":
+		return &node{types: []string{}}, nil
+	}
+
+	var raw rawNode
+	if err := json.Unmarshal(document, &raw); err != nil {
+		return nil, fmt.Errorf("validate: schema %s: %w", describe(path), err)
+	}
+
+	n := &node{
+		enum:      raw.Enum,
+		required:  raw.Required,
+		minItems:  raw.MinItems,
+		maxItems:  raw.MaxItems,
+		minLength: raw.MinLength,
+		maxLength: raw.MaxLength,
+		minimum:   raw.Minimum,
+		maximum:   raw.Maximum,
+	}
+
+	if len(raw.Type) > 0 {
+		var single string
+		if err := json.Unmarshal(raw.Type, &single); err == nil {
+			n.types = []string{single}
+		} else if err = json.Unmarshal(raw.Type, &n.types); err != nil {
+			return nil, fmt.Errorf("validate: schema %s: type must be a string or array of strings", describe(path))
+		}
+	}
+
+	if len(raw.Const) > 0 {
+		n.hasConst = true
+		if err := json.Unmarshal(raw.Const, &n.constant); err != nil {
+			return nil, fmt.Errorf("validate: schema %s: %w", describe(path), err)
+		}
+	}
+
+	if raw.Pattern != "" {
+		pattern, err := regexp.Compile(raw.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("validate: schema %s: %w", describe(path), err)
+		}
+		n.pattern = pattern
+	}
+
+	if len(raw.Properties) > 0 {
+		n.properties = make(map[string]*node, len(raw.Properties))
+		for name, document := range raw.Properties {
+			property, err := compile(document, join(path, name))
+			if err != nil {
+				return nil, err
+			}
+			n.properties[name] = property
+		}
+	}
+
+	if len(raw.AdditionalProperties) > 0 {
+		switch strings.TrimSpace(string(raw.AdditionalProperties)) {
+		case "false":
+			n.forbidden = true
+		case "true":
+		default:
+			additional, err := compile(raw.AdditionalProperties, join(path, "*"))
+			if err != nil {
+				return nil, err
+			}
+			n.additional = additional
+		}
+	}
+
+	if len(raw.Items) > 0 {
+		items, err := compile(raw.Items, path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		n.items = items
+	}
+	return n, nil
+}
+
+func (n *node) validate(value interface{}, path string) error {
+	if n.types != nil && !n.matchesType(value) {
+		if len(n.types) == 0 {
+			return &FieldError{Field: path, Reason: "is not allowed"}
+		}
+		return &FieldError{Field: path, Reason: fmt.Sprintf("must be of type %s", strings.Join(n.types, " or "))}
+	}
+
+	if n.hasConst && !reflect.DeepEqual(value, n.constant) {
+		return &FieldError{Field: path, Reason: fmt.Sprintf("must be %v", n.constant)}
+	}
+
+	if n.enum != nil {
+		var found bool
+		for _, option := range n.enum {
+			if reflect.DeepEqual(value, option) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &FieldError{Field: path, Reason: "is not one of the allowed values"}
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return n.validateString(v, path)
+	case float64:
+		return n.validateNumber(v, path)
+	case []interface{}:
+		return n.validateArray(v, path)
+	case map[string]interface{}:
+		return n.validateObject(v, path)
+	}
+	return nil
+}
+
+func (n *node) matchesType(value interface{}) bool {
+	for _, t := range n.types {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *node) validateString(v string, path string) error {
+	length := utf8.RuneCountInString(v)
+	if n.minLength != nil && length < *n.minLength {
+		return &FieldError{Field: path, Reason: fmt.Sprintf("must be at least %d characters", *n.minLength)}
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		return &FieldError{Field: path, Reason: fmt.Sprintf("must be at most %d characters", *n.maxLength)}
+	}
+	// the value itself is left out of the reason as it is likely to be sensitive
+	if n.pattern != nil && !n.pattern.MatchString(v) {
+		return &FieldError{Field: path, Reason: "does not match pattern " + n.pattern.String()}
+	}
+	return nil
+}
+
+func (n *node) validateNumber(v float64, path string) error {
+	if n.minimum != nil && v < *n.minimum {
+		return &FieldError{Field: path, Reason: fmt.Sprintf("must be at least %v", *n.minimum)}
+	}
+	if n.maximum != nil && v > *n.maximum {
+		return &FieldError{Field: path, Reason: fmt.Sprintf("must be at most %v", *n.maximum)}
+	}
+	return nil
+}
+
+func (n *node) validateArray(v []interface{}, path string) error {
+	if n.minItems != nil && len(v) < *n.minItems {
+		return &FieldError{Field: path, Reason: fmt.Sprintf("must have at least %d items", *n.minItems)}
+	}
+	if n.maxItems != nil && len(v) > *n.maxItems {
+		return &FieldError{Field: path, Reason: fmt.Sprintf("must have at most %d items", *n.maxItems)}
+	}
+	if n.items != nil {
+		for i, item := range v {
+			if err := n.items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (n *node) validateObject(v map[string]interface{}, path string) error {
+	for _, name := range n.required {
+		if _, ok := v[name]; !ok {
+			return &FieldError{Field: join(path, name), Reason: "is required"}
+		}
+	}
+
+	// fields are checked in a stable order so the same document always reports the same error
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := n.properties[name]; ok {
+			if err := property.validate(v[name], join(path, name)); err != nil {
+				return err
+			}
+			continue
+		}
+		if n.forbidden {
+			return &FieldError{Field: join(path, name), Reason: "is not allowed"}
+		}
+		if n.additional != nil {
+			if err := n.additional.validate(v[name], join(path, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func describe(path string) string {
+	if path == "" {
+		return "root"
+	}
+	return path
+}
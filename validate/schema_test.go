@@ -0,0 +1,167 @@
+package validate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/jsonsecret"
+	"github.com/printerlogic/go-secretsmanager-rotate/validate"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const databaseSchema = `{
+	"type": "object",
+	"required": ["username", "password", "port"],
+	"properties": {
+		"username": {"type": "string", "minLength": 1},
+		"password": {"type": "string", "minLength": 12, "pattern": "[0-9]"},
+		"port": {"type": "integer", "minimum": 1, "maximum": 65535},
+		"engine": {"enum": ["postgres", "mysql"]},
+		"replicas": {"type": "array", "maxItems": 2, "items": {"type": "string"}}
+	},
+	"additionalProperties": false
+}`
+
+func TestSchema(t *testing.T) {
+	schema, err := validate.CompileSchema([]byte(databaseSchema))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("valid documents pass", func(t *testing.T) {
+		secret := rotate.StringSecret(`{"username": "app", "password": "correct-horse-1", "port": 5432, "engine": "postgres", "replicas": ["a", "b"]}`)
+		assert.NoError(t, schema.Validate(secret))
+	})
+
+	t.Run("violations are reported against the offending field", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			secret string
+			field  string
+		}{
+			{"missing required field", `{"username": "app", "port": 5432}`, "password"},
+			{"wrong type", `{"username": "app", "password": "correct-horse-1", "port": "5432"}`, "port"},
+			{"not an integer", `{"username": "app", "password": "correct-horse-1", "port": 54.32}`, "port"},
+			{"below minimum", `{"username": "app", "password": "correct-horse-1", "port": 0}`, "port"},
+			{"too short", `{"username": "app", "password": "short-1", "port": 5432}`, "password"},
+			{"pattern mismatch", `{"username": "app", "password": "correct-horse-battery", "port": 5432}`, "password"},
+			{"not in enum", `{"username": "app", "password": "correct-horse-1", "port": 5432, "engine": "oracle"}`, "engine"},
+			{"too many items", `{"username": "app", "password": "correct-horse-1", "port": 5432, "replicas": ["a", "b", "c"]}`, "replicas"},
+			{"invalid item", `{"username": "app", "password": "correct-horse-1", "port": 5432, "replicas": ["a", 2]}`, "replicas[1]"},
+			{"additional property", `{"username": "app", "password": "correct-horse-1", "port": 5432, "extra": true}`, "extra"},
+			{"not an object", `["app"]`, ""},
+			{"not json", `username=app`, ""},
+		}
+
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				err := schema.Validate(rotate.StringSecret(c.secret))
+
+				var fieldErr *validate.FieldError
+				if assert.True(t, errors.As(err, &fieldErr), "expected a *FieldError, got %v", err) {
+					assert.Equal(t, c.field, fieldErr.Field)
+				}
+			})
+		}
+	})
+
+	t.Run("reasons do not include the secret value", func(t *testing.T) {
+		err := schema.Validate(rotate.StringSecret(`{"username": "app", "password": "hunter-hunter", "port": 5432}`))
+		if assert.Error(t, err) {
+			assert.NotContains(t, err.Error(), "hunter")
+		}
+	})
+
+	t.Run("nested schemas report the full path", func(t *testing.T) {
+		nested, err := validate.CompileSchema([]byte(`{"properties": {"database": {"required": ["host"], "additionalProperties": {"type": "string"}}}}`))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = nested.Validate(rotate.StringSecret(`{"database": {"port": 5432}}`))
+		assert.EqualError(t, err, "field database.host: is required")
+
+		err = nested.Validate(rotate.StringSecret(`{"database": {"host": "db", "port": 5432}}`))
+		assert.EqualError(t, err, "field database.port: must be of type string")
+	})
+
+	t.Run("invalid schemas fail to compile", func(t *testing.T) {
+		for _, document := range []string{`{"type": 5}`, `{"pattern": "("}`, `{"properties": {"a": []}}`, `not json`} {
+			_, err := validate.CompileSchema([]byte(document))
+			assert.Error(t, err, document)
+		}
+	})
+}
+
+func TestRotatorValidation(t *testing.T) {
+	schema, err := validate.CompileSchema([]byte(`{"required": ["username", "password"]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	event := rotate.Event{SecretId: "my-secret", ClientRequestToken: "version-1", Step: rotate.StepCreate}
+
+	t.Run("schemas see fields missing from the raw document of parsed secrets", func(t *testing.T) {
+		svc := &credentialsService{Schema: schema, SecretParser: jsonsecret.Parser(&credentials{})}
+		err := rotate.New(rotate.Config{SecretsManager: currentOnly(`{"username": "app"}`), Service: svc}).Handle(context.TODO(), event)
+		assert.EqualError(t, err, "invalid AWSCURRENT secret: field password: is required")
+	})
+
+	t.Run("struct tags are checked by the Validate method of parsed secrets", func(t *testing.T) {
+		svc := &credentialsService{Schema: schema, SecretParser: jsonsecret.Parser(&checkedCredentials{})}
+		err := rotate.New(rotate.Config{SecretsManager: currentOnly(`{"username": "app", "password": ""}`), Service: svc}).Handle(context.TODO(), event)
+		assert.EqualError(t, err, "invalid AWSCURRENT secret: field password: is required")
+	})
+}
+
+// credentials is a parsed secret that encodes a missing password as an empty one
+type credentials struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+func (c *credentials) Binary() bool {
+	return false
+}
+
+func (c *credentials) Value() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+type checkedCredentials struct {
+	credentials
+}
+
+func (c *checkedCredentials) Validate() error {
+	return validate.Struct(c)
+}
+
+type credentialsService struct {
+	*validate.Schema
+	rotate.SecretParser
+}
+
+func (s *credentialsService) Create(_ context.Context, current rotate.Secret) (rotate.Secret, error) {
+	return current, nil
+}
+
+// currentOnly serves a single AWSCURRENT version, which is all a create step reads before validating it
+type currentOnly string
+
+func (c currentOnly) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	if aws.ToString(params.VersionStage) != rotate.AWSCURRENT {
+		return nil, errors.New("unexpected lookup")
+	}
+	return &secretsmanager.GetSecretValueOutput{VersionId: aws.String("current"), SecretString: aws.String(string(c))}, nil
+}
+
+func (c currentOnly) PutSecretValue(context.Context, *secretsmanager.PutSecretValueInput, ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	return nil, errors.New("unexpected write")
+}
+
+func (c currentOnly) UpdateSecretVersionStage(context.Context, *secretsmanager.UpdateSecretVersionStageInput, ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	return nil, errors.New("unexpected write")
+}
@@ -0,0 +1,103 @@
+package validate
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FieldError identifies the field of a secret that failed validation
+type FieldError struct {
+	// Field is the path of the field within the secret, such as `database.password` or `hosts[0]`
+	Field string
+
+	// Reason describes why the field is invalid
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return "field " + e.Field + ": " + e.Reason
+}
+
+// Struct checks the `validate` struct tags of v, returning a *FieldError for the first field that fails
+// Supported tags are:
+//
+//	validate:"required"  the field must not be empty
+//
+// Nested and embedded structs are checked recursively, and fields are named by their json tag when present.
+// The rotator does not check struct tags itself, so a parsed secret calls Struct from its Validate() error method.
+func Struct(v interface{}) error {
+	return checkStruct(reflect.ValueOf(v), "")
+}
+
+func checkStruct(rv reflect.Value, path string) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		value := rv.Field(i)
+
+		if field.Anonymous {
+			if err := checkStruct(value, path); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := join(path, fieldName(field))
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			switch strings.TrimSpace(rule) {
+			case "required":
+				if empty(value) {
+					return &FieldError{Field: name, Reason: "is required"}
+				}
+			}
+		}
+
+		if err := checkStruct(value, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if idx := strings.IndexByte(tag, ','); idx >= 0 {
+		tag = tag[:idx]
+	}
+	if tag == "" || tag == "-" {
+		return field.Name
+	}
+	return tag
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func empty(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	default:
+		return rv.IsZero()
+	}
+}
@@ -0,0 +1,68 @@
+package validate_test
+
+import (
+	"errors"
+	"github.com/printerlogic/go-secretsmanager-rotate/validate"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStruct(t *testing.T) {
+	t.Run("complete structs are valid", func(t *testing.T) {
+		assert.NoError(t, validate.Struct(&DatabaseSecret{
+			Username: "app",
+			Password: "secret",
+			Options:  &Options{Host: "db.internal"},
+		}))
+	})
+
+	t.Run("missing required fields are reported by their json name", func(t *testing.T) {
+		err := validate.Struct(DatabaseSecret{Username: "app"})
+
+		var fieldErr *validate.FieldError
+		if assert.True(t, errors.As(err, &fieldErr)) {
+			assert.Equal(t, "password", fieldErr.Field)
+			assert.Equal(t, "is required", fieldErr.Reason)
+		}
+		assert.EqualError(t, err, "field password: is required")
+	})
+
+	t.Run("nested structs are checked with their path", func(t *testing.T) {
+		err := validate.Struct(&DatabaseSecret{Username: "app", Password: "secret", Options: &Options{}})
+
+		var fieldErr *validate.FieldError
+		if assert.True(t, errors.As(err, &fieldErr)) {
+			assert.Equal(t, "options.host", fieldErr.Field)
+		}
+	})
+
+	t.Run("embedded structs are checked without a path", func(t *testing.T) {
+		err := validate.Struct(&TenantSecret{DatabaseSecret: DatabaseSecret{Password: "secret"}, Tenant: "acme"})
+
+		var fieldErr *validate.FieldError
+		if assert.True(t, errors.As(err, &fieldErr)) {
+			assert.Equal(t, "username", fieldErr.Field)
+		}
+	})
+
+	t.Run("non struct values are valid", func(t *testing.T) {
+		assert.NoError(t, validate.Struct("plain"))
+		assert.NoError(t, validate.Struct((*DatabaseSecret)(nil)))
+	})
+}
+
+type DatabaseSecret struct {
+	Username string   `json:"username" validate:"required"`
+	Password string   `json:"password,omitempty" validate:"required"`
+	Options  *Options `json:"options"`
+}
+
+type Options struct {
+	Host string `json:"host" validate:"required"`
+	Port int    `json:"port"`
+}
+
+type TenantSecret struct {
+	DatabaseSecret
+	Tenant string `validate:"required"`
+}
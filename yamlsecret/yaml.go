@@ -0,0 +1,56 @@
+package yamlsecret
+
+import (
+	"bytes"
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"gopkg.in/yaml.v3"
+	"reflect"
+)
+
+// Parser returns a rotate.SecretParser that will unmarshal YAML into structs of the same type as target.
+func Parser(target rotate.Secret) rotate.SecretParser {
+	var ptr bool
+	rt := reflect.TypeOf(target)
+	if rt.Kind() == reflect.Ptr {
+		ptr = true
+		rt = rt.Elem()
+	}
+	return &parser{target: rt, asPtr: ptr}
+}
+
+type parser struct {
+	// target is the pointer type for the rotate.Secret
+	target reflect.Type
+	asPtr  bool
+}
+
+func (p *parser) Parse(s rotate.Secret) (rotate.Secret, error) {
+	data, err := s.Value()
+	if err != nil {
+		return s, err
+	}
+
+	target := reflect.New(p.target)
+	err = yaml.Unmarshal(data, target.Interface())
+	if !p.asPtr {
+		return target.Elem().Interface().(rotate.Secret), err
+	}
+	return target.Interface().(rotate.Secret), err
+}
+
+// Marshal serializes v back to YAML with two space indentation, intended for use when implementing rotate.Secret's
+// Value(). Keys that are not known to a struct can be preserved across a round trip by declaring an inline map field:
+//
+//	Extra map[string]interface{} `yaml:",inline"`
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
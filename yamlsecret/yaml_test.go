@@ -0,0 +1,129 @@
+package yamlsecret_test
+
+import (
+	"github.com/printerlogic/go-secretsmanager-rotate"
+	"github.com/printerlogic/go-secretsmanager-rotate/yamlsecret"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	t.Run("parses into pointer structs when provided a pointer type", func(t *testing.T) {
+		// We seed our parser with a pointer of our desired secret type
+		parser := yamlsecret.Parser(&SimpleSecret{})
+		source := rotate.StringSecret("username: foo\npassword: bar\n")
+
+		secret, err := parser.Parse(source)
+		assert.NoError(t, err)
+		// We cast our returned secret to a pointer because we seeded a pointer of our secret type
+		assert.Equal(t, "foo", secret.(*SimpleSecret).Username)
+		assert.Equal(t, "bar", secret.(*SimpleSecret).Password)
+	})
+
+	t.Run("parses into value structs when provided a value type", func(t *testing.T) {
+		// We seed our parser with a value of our desired secret type
+		parser := yamlsecret.Parser(SimpleSecret{})
+		source := rotate.StringSecret("username: purple\npassword: shoes\n")
+
+		secret, err := parser.Parse(source)
+		assert.NoError(t, err)
+		// We cast our returned secret to a value type because we seeded a value of our secret type
+		assert.Equal(t, "purple", secret.(SimpleSecret).Username)
+		assert.Equal(t, "shoes", secret.(SimpleSecret).Password)
+	})
+
+	t.Run("fails for malformed yaml", func(t *testing.T) {
+		parser := yamlsecret.Parser(&SimpleSecret{})
+		_, err := parser.Parse(rotate.StringSecret("username: [foo\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestMarshal(t *testing.T) {
+	source := `database:
+  host: db.internal
+  port: 5432
+  username: app
+  password: old-password
+  options:
+    sslmode: require
+features:
+  - reports
+  - billing
+`
+
+	t.Run("round trip preserves unknown keys", func(t *testing.T) {
+		secret, err := yamlsecret.Parser(&LegacyConfig{}).Parse(rotate.StringSecret(source))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		config := secret.(*LegacyConfig)
+		assert.Equal(t, "old-password", config.Database.Password)
+		config.Database.Password = "new-password"
+
+		raw, err := config.Value()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var expected, actual map[string]interface{}
+		assert.NoError(t, yaml.Unmarshal([]byte(source), &expected))
+		assert.NoError(t, yaml.Unmarshal(raw, &actual))
+		expected["database"].(map[string]interface{})["password"] = "new-password"
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("round trip of an unmodified secret is stable", func(t *testing.T) {
+		secret, err := yamlsecret.Parser(&LegacyConfig{}).Parse(rotate.StringSecret(source))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		raw, err := secret.Value()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		again, err := yamlsecret.Parser(&LegacyConfig{}).Parse(rotate.StringSecret(raw))
+		assert.NoError(t, err)
+		assert.Equal(t, secret, again)
+	})
+
+	t.Run("uses two space indentation", func(t *testing.T) {
+		raw, err := yamlsecret.Marshal(map[string]interface{}{"outer": map[string]string{"inner": "value"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "outer:\n  inner: value\n", string(raw))
+	})
+}
+
+type SimpleSecret struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+func (s SimpleSecret) Binary() bool {
+	panic("not implement")
+}
+
+func (s SimpleSecret) Value() ([]byte, error) {
+	panic("not implement")
+}
+
+type LegacyConfig struct {
+	Database struct {
+		Username string                 `yaml:"username"`
+		Password string                 `yaml:"password"`
+		Extra    map[string]interface{} `yaml:",inline"`
+	} `yaml:"database"`
+	Extra map[string]interface{} `yaml:",inline"`
+}
+
+func (c *LegacyConfig) Binary() bool {
+	return false
+}
+
+func (c *LegacyConfig) Value() ([]byte, error) {
+	return yamlsecret.Marshal(c)
+}